@@ -0,0 +1,558 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vmihailenco/msgpack"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	JSONMediaType        = "application/json"
+	XMLMediaType         = "application/xml"
+	YAMLMediaType        = "application/yaml"
+	CSVMediaType         = "text/csv"
+	MessagePackMediaType = "application/msgpack"
+)
+
+// Encoder writes a response body. Values are entities, JSON representations (see Fieldset) or collections.
+type Encoder interface {
+	Encode(w io.Writer, value interface{}) error
+}
+
+// SelectiveEncoder is implemented by Encoders limited to some values, e.g. CSV which only encodes collections.
+type SelectiveEncoder interface {
+	Encoder
+	CanEncode(value interface{}) bool
+}
+
+// Decoder reads a request body into value, a pointer to an empty interface filled with the maps, slices and scalars encoding/json would produce.
+type Decoder interface {
+	Decode(r io.Reader, value interface{}) error
+}
+
+type registeredEncoder struct {
+	mediaType string
+	encoder   Encoder
+}
+
+// Codecs selects Encoders from the Accept header and Decoders from the Content-Type header.
+type Codecs struct {
+	encoders []registeredEncoder
+	decoders map[string]Decoder
+}
+
+func NewCodecs() *Codecs {
+	return &Codecs{decoders: make(map[string]Decoder)}
+}
+
+// DefaultCodecs supports JSON (used when any media type is accepted), XML, YAML, CSV for collections and MessagePack.
+func DefaultCodecs() *Codecs {
+	codecs := NewCodecs()
+	codecs.Register(JSONMediaType, JSONCodec{})
+	codecs.Register(XMLMediaType, XMLCodec{})
+	codecs.Register("text/xml", XMLCodec{})
+	codecs.Register(YAMLMediaType, YAMLCodec{})
+	codecs.Register("application/x-yaml", YAMLCodec{})
+	codecs.RegisterEncoder(CSVMediaType, CSVEncoder{})
+	codecs.Register(MessagePackMediaType, MessagePackCodec{})
+	codecs.Register("application/x-msgpack", MessagePackCodec{})
+	return codecs
+}
+
+var defaultCodecs = DefaultCodecs()
+
+// Register adds a codec able to both encode and decode the media type.
+func (c *Codecs) Register(mediaType string, codec interface {
+	Encoder
+	Decoder
+}) {
+	c.RegisterEncoder(mediaType, codec)
+	c.RegisterDecoder(mediaType, codec)
+}
+
+// RegisterEncoder adds an encoder. When several media types are acceptable, the first registered one is preferred.
+func (c *Codecs) RegisterEncoder(mediaType string, encoder Encoder) {
+	c.encoders = append(c.encoders, registeredEncoder{mediaType: strings.ToLower(mediaType), encoder: encoder})
+}
+
+func (c *Codecs) RegisterDecoder(mediaType string, decoder Decoder) {
+	c.decoders[strings.ToLower(mediaType)] = decoder
+}
+
+// Encoder returns the media type and encoder preferred by the Accept header among the ones able to encode value.
+// Each media type gets the quality of the most specific range matching it, so that `*/*, application/xml;q=0` excludes XML.
+// Ties go to the most specific range, then to the first registered encoder.
+func (c *Codecs) Encoder(accept string, value interface{}) (string, Encoder, error) {
+	accepted := parseAccept(accept)
+	var best *registeredEncoder
+	var bestRange acceptedMediaType
+	for i, registered := range c.encoders {
+		matching, found := mostSpecificRange(accepted, registered.mediaType)
+		if !found || matching.quality <= 0 {
+			continue
+		}
+		if nil != best && (matching.quality < bestRange.quality || (matching.quality == bestRange.quality && matching.specificity() <= bestRange.specificity())) {
+			continue
+		}
+		if selective, ok := registered.encoder.(SelectiveEncoder); ok && !selective.CanEncode(value) {
+			continue
+		}
+		best = &c.encoders[i]
+		bestRange = matching
+	}
+	if nil == best {
+		return "", nil, NewError(KindNotAcceptable, nil, "No acceptable representation for '%s'", accept)
+	}
+	return best.mediaType, best.encoder, nil
+}
+
+// Decoder returns the decoder of the Content-Type header. A missing Content-Type is read as JSON.
+func (c *Codecs) Decoder(contentType string) (Decoder, error) {
+	mediaType := JSONMediaType
+	if "" != contentType {
+		var err error
+		if mediaType, _, err = mime.ParseMediaType(contentType); nil != err {
+			return nil, NewError(KindUnsupportedMediaType, err, "Invalid content type '%s'", contentType)
+		}
+	}
+	decoder, found := c.decoders[mediaType]
+	if !found {
+		return nil, NewError(KindUnsupportedMediaType, nil, "Unsupported content type '%s'", contentType)
+	}
+	return decoder, nil
+}
+
+// encode negotiates the representation of value from an Accept header and returns its media type and body.
+func (c *Codecs) encode(accept string, value interface{}) (string, []byte, error) {
+	mediaType, encoder, err := c.Encoder(accept, value)
+	if err != nil {
+		return "", nil, err
+	}
+	var buffer bytes.Buffer
+	if err = encoder.Encode(&buffer, value); nil != err {
+		return "", nil, errors.Wrapf(err, "Encoding %s", mediaType)
+	}
+	return mediaType, buffer.Bytes(), nil
+}
+
+// encoderMediaTypes lists the media types able to encode value, in preference order.
+func (c *Codecs) encoderMediaTypes(value interface{}) []string {
+	mediaTypes := make([]string, 0, len(c.encoders))
+	for _, registered := range c.encoders {
+		if selective, ok := registered.encoder.(SelectiveEncoder); ok && !selective.CanEncode(value) {
+			continue
+		}
+		mediaTypes = append(mediaTypes, registered.mediaType)
+	}
+	return mediaTypes
+}
+
+func (c *Codecs) decoderMediaTypes() []string {
+	mediaTypes := make([]string, 0, len(c.decoders))
+	for mediaType := range c.decoders {
+		mediaTypes = append(mediaTypes, mediaType)
+	}
+	sort.Strings(mediaTypes)
+	return mediaTypes
+}
+
+type acceptedMediaType struct {
+	mediaType string
+	quality   float64
+}
+
+func (a acceptedMediaType) matches(mediaType string) bool {
+	if "*/*" == a.mediaType || a.mediaType == mediaType {
+		return true
+	}
+	return strings.HasSuffix(a.mediaType, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(a.mediaType, "*"))
+}
+
+// specificity ranks */* below type/* and type/* below full media types.
+func (a acceptedMediaType) specificity() int {
+	switch {
+	case "*/*" == a.mediaType:
+		return 0
+	case strings.HasSuffix(a.mediaType, "/*"):
+		return 1
+	}
+	return 2
+}
+
+func mostSpecificRange(accepted []acceptedMediaType, mediaType string) (acceptedMediaType, bool) {
+	var mostSpecific acceptedMediaType
+	found := false
+	for _, candidate := range accepted {
+		if candidate.matches(mediaType) && (!found || candidate.specificity() > mostSpecific.specificity()) {
+			mostSpecific = candidate
+			found = true
+		}
+	}
+	return mostSpecific, found
+}
+
+// parseAccept returns the media ranges by decreasing quality, then decreasing specificity. Ranges of quality 0 are kept,
+// as they exclude media types matched by less specific ranges. An empty header accepts anything.
+func parseAccept(accept string) []acceptedMediaType {
+	if "" == strings.TrimSpace(accept) {
+		return []acceptedMediaType{{mediaType: "*/*", quality: 1}}
+	}
+	accepted := make([]acceptedMediaType, 0)
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, found := params["q"]; found {
+			if quality, err = strconv.ParseFloat(q, 64); nil != err || quality < 0 || quality > 1 {
+				continue
+			}
+		}
+		accepted = append(accepted, acceptedMediaType{mediaType: mediaType, quality: quality})
+	}
+	sort.SliceStable(accepted, func(i, j int) bool {
+		if accepted[i].quality != accepted[j].quality {
+			return accepted[i].quality > accepted[j].quality
+		}
+		return accepted[i].specificity() > accepted[j].specificity()
+	})
+	return accepted
+}
+
+// representation converts the value to its JSON representation, so every codec honours json tags and projections.
+func representation(value interface{}) (interface{}, error) {
+	if collection, ok := value.(*CollectionResponse); ok {
+		value = *collection
+	}
+	return jsonRepresentation(value)
+}
+
+type JSONCodec struct{}
+
+func (JSONCodec) Encode(w io.Writer, value interface{}) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(encoded)
+	return err
+}
+
+func (JSONCodec) Decode(r io.Reader, value interface{}) error {
+	return json.NewDecoder(r).Decode(value)
+}
+
+type YAMLCodec struct{}
+
+func (YAMLCodec) Encode(w io.Writer, value interface{}) error {
+	repr, err := representation(value)
+	if err != nil {
+		return err
+	}
+	return yaml.NewEncoder(w).Encode(repr)
+}
+
+func (YAMLCodec) Decode(r io.Reader, value interface{}) error {
+	var decoded interface{}
+	if err := yaml.NewDecoder(r).Decode(&decoded); nil != err {
+		return err
+	}
+	return assign(value, normalize(decoded))
+}
+
+type MessagePackCodec struct{}
+
+func (MessagePackCodec) Encode(w io.Writer, value interface{}) error {
+	repr, err := representation(value)
+	if err != nil {
+		return err
+	}
+	// Sorted keys give identical bytes, and ETags, for identical entities
+	return msgpack.NewEncoder(w).SortMapKeys(true).Encode(repr)
+}
+
+func (MessagePackCodec) Decode(r io.Reader, value interface{}) error {
+	var decoded interface{}
+	if err := msgpack.NewDecoder(r).Decode(&decoded); nil != err {
+		return err
+	}
+	return assign(value, normalize(decoded))
+}
+
+func assign(value interface{}, decoded interface{}) error {
+	target, ok := value.(*interface{})
+	if !ok {
+		return fmt.Errorf("Cannot decode into %T, expected *interface{}", value)
+	}
+	*target = decoded
+	return nil
+}
+
+// normalize turns the map[interface{}]interface{} of YAML and MessagePack into JSON objects.
+func normalize(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[interface{}]interface{}:
+		object := make(map[string]interface{}, len(typed))
+		for key, element := range typed {
+			object[fmt.Sprint(key)] = normalize(element)
+		}
+		return object
+	case map[string]interface{}:
+		for key, element := range typed {
+			typed[key] = normalize(element)
+		}
+		return typed
+	case []interface{}:
+		for i, element := range typed {
+			typed[i] = normalize(element)
+		}
+		return typed
+	}
+	return value
+}
+
+// XMLCodec maps JSON representations to XML. Objects become child elements, array elements are <item> elements.
+// Non string scalars, arrays and nulls carry a type attribute (number, boolean, array, null) so decoding restores them;
+// elements without attribute decode as strings or objects.
+type XMLCodec struct{}
+
+const xmlRoot = "resource"
+
+func (XMLCodec) Encode(w io.Writer, value interface{}) error {
+	repr, err := representation(value)
+	if err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	if err = encodeXML(encoder, xmlRoot, repr); nil != err {
+		return err
+	}
+	return encoder.Flush()
+}
+
+func encodeXML(encoder *xml.Encoder, name string, value interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	var text string
+	switch typed := value.(type) {
+	case nil:
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "type"}, Value: "null"})
+	case bool:
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "type"}, Value: "boolean"})
+		text = strconv.FormatBool(typed)
+	case float64:
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "type"}, Value: "number"})
+		text = strconv.FormatFloat(typed, 'f', -1, 64)
+	case string:
+		text = typed
+	case []interface{}:
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "type"}, Value: "array"})
+	}
+	if err := encoder.EncodeToken(start); nil != err {
+		return err
+	}
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(typed))
+		for key := range typed {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := encodeXML(encoder, key, typed[key]); nil != err {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, element := range typed {
+			if err := encodeXML(encoder, "item", element); nil != err {
+				return err
+			}
+		}
+	default:
+		if "" != text {
+			if err := encoder.EncodeToken(xml.CharData(text)); nil != err {
+				return err
+			}
+		}
+	}
+	return encoder.EncodeToken(start.End())
+}
+
+func (XMLCodec) Decode(r io.Reader, value interface{}) error {
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return errors.Wrapf(err, "Looking for XML root element")
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			decoded, err := decodeXML(decoder, start)
+			if err != nil {
+				return err
+			}
+			return assign(value, decoded)
+		}
+	}
+}
+
+func decodeXML(decoder *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	var kind string
+	for _, attr := range start.Attr {
+		if "type" == attr.Name.Local {
+			kind = attr.Value
+		}
+	}
+	var text strings.Builder
+	children := make(map[string]interface{})
+	items := make([]interface{}, 0)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, errors.Wrapf(err, "Reading XML element '%s'", start.Name.Local)
+		}
+		switch typed := token.(type) {
+		case xml.StartElement:
+			child, err := decodeXML(decoder, typed)
+			if err != nil {
+				return nil, err
+			}
+			if "array" == kind {
+				items = append(items, child)
+			} else {
+				children[typed.Name.Local] = child
+			}
+		case xml.CharData:
+			text.Write(typed)
+		case xml.EndElement:
+			return xmlValue(start.Name.Local, kind, strings.TrimSpace(text.String()), children, items)
+		}
+	}
+}
+
+func xmlValue(name, kind, text string, children map[string]interface{}, items []interface{}) (interface{}, error) {
+	switch kind {
+	case "null":
+		return nil, nil
+	case "array":
+		return items, nil
+	case "boolean":
+		value, err := strconv.ParseBool(text)
+		return value, errors.Wrapf(err, "Invalid boolean in '%s'", name)
+	case "number":
+		value, err := strconv.ParseFloat(text, 64)
+		return value, errors.Wrapf(err, "Invalid number in '%s'", name)
+	}
+	if 0 != len(children) {
+		return children, nil
+	}
+	return text, nil
+}
+
+// CSVEncoder writes the items of collections as rows. Nested objects are flattened into dotted columns, arrays are written as JSON.
+type CSVEncoder struct{}
+
+func (CSVEncoder) CanEncode(value interface{}) bool {
+	switch value.(type) {
+	case CollectionResponse, *CollectionResponse, []interface{}:
+		return true
+	}
+	return false
+}
+
+func (CSVEncoder) Encode(w io.Writer, value interface{}) error {
+	var items []interface{}
+	switch typed := value.(type) {
+	case CollectionResponse:
+		items = typed.Items
+	case *CollectionResponse:
+		items = typed.Items
+	case []interface{}:
+		items = typed
+	default:
+		return fmt.Errorf("CSV only encodes collections, not %T", value)
+	}
+
+	rows := make([]map[string]string, 0, len(items))
+	columns := make(map[string]bool)
+	for _, item := range items {
+		repr, err := jsonRepresentation(item)
+		if err != nil {
+			return err
+		}
+		row := make(map[string]string)
+		if err = flattenCSV(row, "", repr); nil != err {
+			return err
+		}
+		for column := range row {
+			columns[column] = true
+		}
+		rows = append(rows, row)
+	}
+	header := make([]string, 0, len(columns))
+	for column := range columns {
+		header = append(header, column)
+	}
+	sort.Strings(header)
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); nil != err {
+		return err
+	}
+	for _, row := range rows {
+		record := make([]string, 0, len(header))
+		for _, column := range header {
+			record = append(record, row[column])
+		}
+		if err := writer.Write(record); nil != err {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// flattenCSV writes the cells of value into row. Items that are not objects, like URLs, go in a "value" column.
+func flattenCSV(row map[string]string, prefix string, value interface{}) error {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, element := range typed {
+			if err := flattenCSV(row, prefix+key+".", element); nil != err {
+				return err
+			}
+		}
+		return nil
+	}
+	column := strings.TrimSuffix(prefix, ".")
+	if "" == column {
+		column = "value"
+	}
+	switch typed := value.(type) {
+	case nil:
+		row[column] = ""
+	case string:
+		row[column] = typed
+	case float64:
+		row[column] = strconv.FormatFloat(typed, 'f', -1, 64)
+	case bool:
+		row[column] = strconv.FormatBool(typed)
+	default:
+		encoded, err := json.Marshal(typed)
+		if err != nil {
+			return err
+		}
+		row[column] = string(encoded)
+	}
+	return nil
+}
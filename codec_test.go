@@ -0,0 +1,153 @@
+package rest_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/normegil/rest"
+)
+
+func TestCodecsNegotiation(t *testing.T) {
+	codecs := rest.DefaultCodecs()
+	testcases := []struct {
+		accept   string
+		value    interface{}
+		expected string
+	}{
+		{"", testEntity{}, rest.JSONMediaType},
+		{"*/*", testEntity{}, rest.JSONMediaType},
+		{"application/xml", testEntity{}, rest.XMLMediaType},
+		{"application/yaml;q=0.5, application/msgpack", testEntity{}, rest.MessagePackMediaType},
+		{"text/*", testEntity{}, "text/xml"},
+		{"text/csv, application/json;q=0.1", testEntity{}, rest.JSONMediaType},
+		{"text/csv", []interface{}{}, rest.CSVMediaType},
+		{"text/csv", testEntity{}, ""},
+		{"application/json;q=0, image/png", testEntity{}, ""},
+		{"*/*, application/json;q=0", testEntity{}, rest.XMLMediaType},
+		{"application/*;q=0.5, application/json;q=0, */*;q=0.1", testEntity{}, rest.XMLMediaType},
+		{"text/*;q=0.5, */*;q=0.5", testEntity{}, "text/xml"},
+		{"*/*;q=0", testEntity{}, ""},
+	}
+	for _, testdata := range testcases {
+		t.Run(testdata.accept, func(t *testing.T) {
+			mediaType, _, err := codecs.Encoder(testdata.accept, testdata.value)
+			if "" == testdata.expected {
+				if rest.KindNotAcceptable != rest.ErrorKindOf(err) {
+					t.Errorf("Expected a not acceptable error but got %+v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if testdata.expected != mediaType {
+				t.Errorf("Negotiated media type (%s) doesn't meet the expected result (%s)", mediaType, testdata.expected)
+			}
+		})
+	}
+}
+
+func TestCodecsRoundTrip(t *testing.T) {
+	original := map[string]interface{}{
+		"name":  "name",
+		"size":  float64(3),
+		"valid": true,
+		"none":  nil,
+		"tags":  []interface{}{"a", "b"},
+		"owner": map[string]interface{}{"name": "owner"},
+	}
+	for _, mediaType := range []string{rest.JSONMediaType, rest.XMLMediaType, rest.YAMLMediaType, rest.MessagePackMediaType} {
+		t.Run(mediaType, func(t *testing.T) {
+			codecs := rest.DefaultCodecs()
+			_, encoder, err := codecs.Encoder(mediaType, original)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buffer bytes.Buffer
+			if err = encoder.Encode(&buffer, original); nil != err {
+				t.Fatal(err)
+			}
+			decoder, err := codecs.Decoder(mediaType + "; charset=utf-8")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded interface{}
+			if err = decoder.Decode(&buffer, &decoded); nil != err {
+				t.Fatal(err)
+			}
+			// Compare through JSON, as YAML and MessagePack keep integers
+			expected, _ := json.Marshal(original)
+			result, _ := json.Marshal(decoded)
+			if !reflect.DeepEqual(expected, result) {
+				t.Errorf("Decoded value (%s) doesn't meet the expected result (%s)", string(result), string(expected))
+			}
+		})
+	}
+}
+
+func TestMessagePackCodecStableETag(t *testing.T) {
+	entity := map[string]interface{}{"id": "1", "name": "name", "size": 3, "tags": []string{"a"}, "owner": map[string]interface{}{"id": "2", "name": "owner"}}
+	var etag string
+	for i := 0; i < 20; i++ {
+		var buffer bytes.Buffer
+		if err := (rest.MessagePackCodec{}).Encode(&buffer, entity); nil != err {
+			t.Fatal(err)
+		}
+		encodedETag := rest.ETag(entity, rest.MessagePackMediaType, buffer.Bytes())
+		if "" == etag {
+			etag = encodedETag
+		}
+		if etag != encodedETag {
+			t.Fatalf("ETag (%s) of encoding %d doesn't meet the expected result (%s)", encodedETag, i, etag)
+		}
+	}
+}
+
+func TestDefaultControllerContentNegotiation(t *testing.T) {
+	dao := rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{})
+	ctrl := rest.NewController("things", dao, nil, newTestUnmarshaller)
+
+	request := httptest.NewRequest("POST", "http://localhost/things", strings.NewReader("name: created\n"))
+	request.Header.Set("Content-Type", rest.YAMLMediaType)
+	request.Header.Set("Accept", rest.XMLMediaType)
+	result := httptest.NewRecorder()
+	ctrl.Create(result, request, nil)
+	if http.StatusCreated != result.Code {
+		t.Fatalf("Create returned %d: %s", result.Code, result.Body.String())
+	}
+	if rest.XMLMediaType != result.Header().Get("Content-Type") || !strings.Contains(result.Body.String(), "<name>created</name>") {
+		t.Errorf("Unexpected XML response %s: %s", result.Header().Get("Content-Type"), result.Body.String())
+	}
+
+	request = httptest.NewRequest("GET", "http://localhost/things?expand=true", nil)
+	request.Header.Set("Accept", rest.CSVMediaType)
+	result = httptest.NewRecorder()
+	ctrl.GetAll(result, request, nil)
+	lines := strings.Split(strings.TrimSpace(result.Body.String()), "\n")
+	if 2 != len(lines) || "id,name" != lines[0] || !strings.HasSuffix(lines[1], ",created") {
+		t.Fatalf("Unexpected CSV collection: %s", result.Body.String())
+	}
+
+	id := strings.TrimSuffix(lines[1], ",created")
+	request = httptest.NewRequest("GET", "http://localhost/things/"+id, nil)
+	request.Header.Set("Accept", rest.CSVMediaType)
+	result = httptest.NewRecorder()
+	ctrl.Get(result, request, httprouter.Params{{Key: "id", Value: id}})
+	if http.StatusNotAcceptable != result.Code {
+		t.Errorf("CSV entity returned %d instead of %d", result.Code, http.StatusNotAcceptable)
+	}
+
+	request = httptest.NewRequest("PUT", "http://localhost/things/"+id, strings.NewReader("name"))
+	request.Header.Set("Content-Type", "text/plain")
+	result = httptest.NewRecorder()
+	ctrl.Update(result, request, httprouter.Params{{Key: "id", Value: id}})
+	if http.StatusUnsupportedMediaType != result.Code {
+		t.Errorf("Plain text body returned %d instead of %d", result.Code, http.StatusUnsupportedMediaType)
+	}
+}
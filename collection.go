@@ -2,8 +2,10 @@ package rest
 
 import (
 	"math"
+	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 
 	urlFormats "github.com/normegil/formats/url"
 	"github.com/pkg/errors"
@@ -21,11 +23,40 @@ type CollectionResponse struct {
 	Items              []interface{}  `json:"items,omitempty"`
 }
 
+// LinkHeader returns the navigation links formatted as an RFC 8288 Link header.
+func (r *CollectionResponse) LinkHeader() string {
+	links := make([]string, 0, 4)
+	for _, link := range []struct {
+		rel    string
+		target string
+	}{
+		{"first", r.First},
+		{"last", r.Last},
+		{"prev", r.Previous},
+		{"next", r.Next},
+	} {
+		if "" != link.target {
+			links = append(links, "<"+link.target+">; rel=\""+link.rel+"\"")
+		}
+	}
+	return strings.Join(links, ", ")
+}
+
+// WriteHeaders sets the Link and X-Total-Count headers describing the collection.
+func (r *CollectionResponse) WriteHeaders(header http.Header) {
+	if link := r.LinkHeader(); "" != link {
+		header.Set("Link", link)
+	}
+	header.Set("X-Total-Count", strconv.FormatInt(r.TotalNumberOfItems, 10))
+}
+
 type CollectionResponseBuilder struct {
 	BaseURL     url.URL
 	MaxNBItems  int64
 	QueryParams url.Values
 	Items       []interface{}
+	Cursors     *CursorCodec
+	Page        CursorPage
 }
 
 func (b *CollectionResponseBuilder) WithBaseURI(baseURL url.URL) *CollectionResponseBuilder {
@@ -48,11 +79,20 @@ func (b *CollectionResponseBuilder) WithItems(items []interface{}) *CollectionRe
 	return b
 }
 
+func (b *CollectionResponseBuilder) WithCursors(codec *CursorCodec, page CursorPage) *CollectionResponseBuilder {
+	b.Cursors = codec
+	b.Page = page
+	return b
+}
+
 func (b *CollectionResponseBuilder) Build() (*CollectionResponse, error) {
 	pagination, err := loadPaginationInfo(b.QueryParams)
 	if err != nil {
 		return nil, errors.Wrapf(err, "Loading pagination info from url parameters")
 	}
+	if nil != b.Cursors {
+		return b.buildWithCursors(pagination)
+	}
 	current, err := collectionURL(b.BaseURL, pagination.Offset(), pagination.Limit(), b.QueryParams)
 	if err != nil {
 		return nil, err
@@ -82,7 +122,7 @@ func (b *CollectionResponseBuilder) Build() (*CollectionResponse, error) {
 		return nil, err
 	}
 	return &CollectionResponse{
-		Current:            urlFormats.URL{current},
+		Current:            urlFormats.URL{URL: current},
 		First:              firstCollectionURL,
 		Last:               lastCollectionURL,
 		Previous:           previous.String(),
@@ -94,6 +134,75 @@ func (b *CollectionResponseBuilder) Build() (*CollectionResponse, error) {
 	}, nil
 }
 
+func (b *CollectionResponseBuilder) buildWithCursors(pagination Pagination) (*CollectionResponse, error) {
+	response := &CollectionResponse{
+		Current:            urlFormats.URL{URL: cursorURL(b.BaseURL, pagination.limit, b.QueryParams)},
+		Limit:              pagination.Limit(),
+		TotalNumberOfItems: b.MaxNBItems,
+		Items:              b.Items,
+	}
+	if b.Page.HasPrevious {
+		response.First = cursorURL(b.BaseURL, pagination.limit, withoutCursor(b.QueryParams)).String()
+		previous, err := b.cursorLink(pagination.limit, Keyset{Key: b.Page.FirstKey, Backward: true})
+		if err != nil {
+			return nil, err
+		}
+		response.Previous = previous
+	}
+	if b.Page.HasNext {
+		last, err := b.cursorLink(pagination.limit, Keyset{Backward: true})
+		if err != nil {
+			return nil, err
+		}
+		response.Last = last
+		next, err := b.cursorLink(pagination.limit, Keyset{Key: b.Page.LastKey})
+		if err != nil {
+			return nil, err
+		}
+		response.Next = next
+	}
+	return response, nil
+}
+
+func (b *CollectionResponseBuilder) cursorLink(limit int64, keyset Keyset) (string, error) {
+	cursor, err := b.Cursors.Encode(keyset)
+	if err != nil {
+		return "", err
+	}
+	params := withoutCursor(b.QueryParams)
+	if keyset.Backward {
+		params.Set("before", cursor)
+	} else {
+		params.Set("after", cursor)
+	}
+	return cursorURL(b.BaseURL, limit, params).String(), nil
+}
+
+func withoutCursor(queryParams url.Values) url.Values {
+	params := url.Values{}
+	for key, values := range queryParams {
+		if "after" != key && "before" != key {
+			params[key] = values
+		}
+	}
+	return params
+}
+
+func cursorURL(baseURL url.URL, limit int64, queryParams url.Values) *url.URL {
+	params := url.Values{}
+	for key, values := range queryParams {
+		params[key] = values
+	}
+	params.Del("offset")
+	params.Del("limit")
+	if limit > 0 {
+		params.Set("limit", strconv.FormatInt(limit, 10))
+	}
+	link := baseURL
+	link.RawQuery = params.Encode()
+	return &link
+}
+
 func generateLastURL(baseURL url.URL, offset int64, limit int64, maxNbItems int64, queryParams url.Values) (*url.URL, error) {
 	if limit <= 0 {
 		return &baseURL, nil
@@ -143,6 +252,9 @@ func collectionURL(baseURL url.URL, offset int64, limit int64, queryParams url.V
 			collectionURL += "offset=" + strconv.FormatInt(offset, 10) + "&limit=" + strconv.FormatInt(limit, 10)
 		}
 		if paramsStr != "" {
+			if limit > 0 {
+				collectionURL += "&"
+			}
 			collectionURL += paramsStr
 		}
 	}
@@ -181,7 +293,10 @@ func loadPaginationInfo(params url.Values) (Pagination, error) {
 	if "" != offsetStr {
 		offset, err = strconv.ParseInt(offsetStr, 10, 64)
 		if err != nil {
-			return Pagination{}, errors.Wrapf(err, "Parsing offset '%s' into int64", offsetStr)
+			return Pagination{}, NewError(KindValidation, err, "Invalid offset '%s'", offsetStr)
+		}
+		if offset < 0 {
+			return Pagination{}, NewError(KindValidation, nil, "Offset cannot be negative (%d)", offset)
 		}
 	}
 
@@ -190,7 +305,10 @@ func loadPaginationInfo(params url.Values) (Pagination, error) {
 	if "" != limitStr {
 		limit, err = strconv.ParseInt(limitStr, 10, 64)
 		if err != nil {
-			return Pagination{}, errors.Wrapf(err, "Parsing limit '%s' into int", limitStr)
+			return Pagination{}, NewError(KindValidation, err, "Invalid limit '%s'", limitStr)
+		}
+		if limit < 0 {
+			return Pagination{}, NewError(KindValidation, nil, "Limit cannot be negative (%d)", limit)
 		}
 	}
 
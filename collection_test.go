@@ -0,0 +1,59 @@
+package rest_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/normegil/rest"
+)
+
+func TestDefaultControllerLinkHeader(t *testing.T) {
+	dao := rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{})
+	for i := 0; i < 5; i++ {
+		if _, err := dao.Set(testEntity{Name: "entity-" + strconv.Itoa(i)}); nil != err {
+			t.Fatal(err)
+		}
+	}
+	ctrl := rest.NewController("things", dao, nil, nil)
+	ctrl.BareCollections = true
+
+	result := httptest.NewRecorder()
+	ctrl.GetAll(result, httptest.NewRequest("GET", "http://localhost/things?offset=2&limit=2", nil), httprouter.Params{})
+	if http.StatusOK != result.Code {
+		t.Fatalf("GetAll returned %d: %s", result.Code, result.Body.String())
+	}
+	expectedLink := `<http://localhost/things?offset=0&limit=2>; rel="first", ` +
+		`<http://localhost/things?offset=4&limit=2>; rel="last", ` +
+		`<http://localhost/things?offset=0&limit=2>; rel="prev", ` +
+		`<http://localhost/things?offset=4&limit=2>; rel="next"`
+	if link := result.Header().Get("Link"); expectedLink != link {
+		t.Errorf("Link header (%s) doesn't meet the expected result (%s)", link, expectedLink)
+	}
+	if total := result.Header().Get("X-Total-Count"); "5" != total {
+		t.Errorf("X-Total-Count header (%s) doesn't meet the expected result (%s)", total, "5")
+	}
+	var items []string
+	if err := json.Unmarshal(result.Body.Bytes(), &items); nil != err {
+		t.Fatalf("Expected a bare array body: %+v", err)
+	}
+	if 2 != len(items) {
+		t.Errorf("Number of items (%d) doesn't meet the expected result (%d)", len(items), 2)
+	}
+}
+
+func TestDefaultControllerInvalidPagination(t *testing.T) {
+	ctrl := rest.NewController("things", rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{}), nil, nil)
+	for _, query := range []string{"offset=-1", "limit=-5", "offset=a", "limit=a"} {
+		t.Run(query, func(t *testing.T) {
+			result := httptest.NewRecorder()
+			ctrl.GetAll(result, httptest.NewRequest("GET", "http://localhost/things?"+query, nil), httprouter.Params{})
+			if http.StatusBadRequest != result.Code {
+				t.Errorf("GetAll returned %d instead of %d: %s", result.Code, http.StatusBadRequest, result.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package rest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Versioned is implemented by entities carrying their own version, used as ETag instead of a hash of the representation.
+// Versions must not contain double quotes.
+type Versioned interface {
+	Version() string
+}
+
+// VersionedEntity is stored with compare-and-set by DAOs: Set fails with a VersionMismatchError unless Version() is the stored version,
+// and stores the entity with the next version. Versions are decimal counters, new entities start at version 1.
+type VersionedEntity interface {
+	IdentifiableEntity
+	Versioned
+	WithVersion(version string) (IdentifiableEntity, error)
+}
+
+const initialVersion = "1"
+
+func nextVersion(version string) (string, error) {
+	counter, err := strconv.ParseInt(version, 10, 64)
+	if err != nil {
+		return "", NewError(KindValidation, err, "Invalid version '%s'", version)
+	}
+	return strconv.FormatInt(counter+1, 10), nil
+}
+
+// withVersion sets the version of VersionedEntity and returns other entities as is.
+func withVersion(entity IdentifiableEntity, version string) (IdentifiableEntity, error) {
+	versioned, ok := entity.(VersionedEntity)
+	if !ok {
+		return entity, nil
+	}
+	updated, err := versioned.WithVersion(version)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Setting version '%s'", version)
+	}
+	return updated, nil
+}
+
+// Timestamped is implemented by entities exposing their modification time, sent as Last-Modified.
+type Timestamped interface {
+	LastModified() time.Time
+}
+
+// ETag returns the strong entity tag of the representation of entity encoded as mediaType. It comes from the version of
+// Versioned entities, suffixed by the media type for representations other than JSON, as each representation needs its
+// own strong tag. It comes from the encoded representation otherwise.
+func ETag(entity Entity, mediaType string, encoded []byte) string {
+	if versioned, ok := entity.(Versioned); ok && "" != versioned.Version() {
+		if "" == mediaType || JSONMediaType == mediaType {
+			return `"` + versioned.Version() + `"`
+		}
+		return `"` + versioned.Version() + "-" + mediaType + `"`
+	}
+	sum := sha256.Sum256(encoded)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+func lastModified(entity Entity) time.Time {
+	if timestamped, ok := entity.(Timestamped); ok {
+		return timestamped.LastModified()
+	}
+	return time.Time{}
+}
+
+// writeValidators sets the ETag and, when known, Last-Modified headers.
+func writeValidators(header http.Header, etag string, modified time.Time) {
+	header.Set("ETag", etag)
+	if !modified.IsZero() {
+		header.Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when the former is absent, for GET and HEAD requests (RFC 7232).
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if http.MethodGet != r.Method && http.MethodHead != r.Method {
+		return false
+	}
+	if ifNoneMatch := r.Header.Get("If-None-Match"); "" != ifNoneMatch {
+		return matchesETag(ifNoneMatch, etag, true)
+	}
+	if modified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !modified.Truncate(time.Second).After(since)
+}
+
+// matchesETag tells whether etag is part of the header list of entity tags. Weak comparison ignores the W/ prefix.
+func matchesETag(header string, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if "*" == candidate {
+			return true
+		}
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		} else if strings.HasPrefix(candidate, "W/") {
+			continue
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,345 @@
+package rest_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/normegil/rest"
+)
+
+type versionedEntity struct {
+	Identifier string    `json:"id,omitempty"`
+	Revision   string    `json:"revision"`
+	Modified   time.Time `json:"modified"`
+}
+
+func (e versionedEntity) ID() rest.Identifier {
+	if "" == e.Identifier {
+		return nil
+	}
+	return rest.StringIdentifier(e.Identifier)
+}
+
+func (e versionedEntity) WithID(id rest.Identifier) (rest.IdentifiableEntity, error) {
+	e.Identifier = id.String()
+	return e, nil
+}
+
+func (e versionedEntity) Version() string {
+	return e.Revision
+}
+
+func (e versionedEntity) LastModified() time.Time {
+	return e.Modified
+}
+
+func TestDefaultControllerConditionalGet(t *testing.T) {
+	modified := time.Date(2020, time.March, 1, 10, 0, 0, 500, time.UTC)
+	dao := rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{})
+	if _, err := dao.Set(versionedEntity{Identifier: "versioned", Revision: "3", Modified: modified}); nil != err {
+		t.Fatal(err)
+	}
+	if _, err := dao.Set(testEntity{Identifier: "plain", Name: "name"}); nil != err {
+		t.Fatal(err)
+	}
+	ctrl := rest.NewController("things", dao, nil, nil)
+	get := func(id string, headers map[string]string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest("GET", "http://localhost/things/"+id, nil)
+		for key, value := range headers {
+			request.Header.Set(key, value)
+		}
+		result := httptest.NewRecorder()
+		ctrl.Get(result, request, httprouter.Params{{Key: "id", Value: id}})
+		return result
+	}
+
+	result := get("versioned", nil)
+	if `"3"` != result.Header().Get("ETag") || "Sun, 01 Mar 2020 10:00:00 GMT" != result.Header().Get("Last-Modified") {
+		t.Errorf("Unexpected validators {ETag:%s;Last-Modified:%s}", result.Header().Get("ETag"), result.Header().Get("Last-Modified"))
+	}
+	plainETag := get("plain", nil).Header().Get("ETag")
+	if "" == plainETag {
+		t.Fatal("No ETag computed from the representation")
+	}
+
+	testcases := []struct {
+		name     string
+		id       string
+		headers  map[string]string
+		expected int
+	}{
+		{"Matching version", "versioned", map[string]string{"If-None-Match": `"2", W/"3"`}, http.StatusNotModified},
+		{"Other version", "versioned", map[string]string{"If-None-Match": `"2"`}, http.StatusOK},
+		{"Any version", "versioned", map[string]string{"If-None-Match": "*"}, http.StatusNotModified},
+		{"Matching hash", "plain", map[string]string{"If-None-Match": plainETag}, http.StatusNotModified},
+		{"Not modified since", "versioned", map[string]string{"If-Modified-Since": "Sun, 01 Mar 2020 10:00:00 GMT"}, http.StatusNotModified},
+		{"Modified since", "versioned", map[string]string{"If-Modified-Since": "Sun, 01 Mar 2020 09:59:59 GMT"}, http.StatusOK},
+		{"If-None-Match takes precedence", "versioned", map[string]string{"If-None-Match": `"2"`, "If-Modified-Since": "Sun, 01 Mar 2020 10:00:00 GMT"}, http.StatusOK},
+	}
+	for _, testdata := range testcases {
+		t.Run(testdata.name, func(t *testing.T) {
+			result := get(testdata.id, testdata.headers)
+			if testdata.expected != result.Code {
+				t.Errorf("Status (%d) doesn't meet the expected result (%d)", result.Code, testdata.expected)
+			}
+			if http.StatusNotModified == result.Code && 0 != result.Body.Len() {
+				t.Errorf("Not modified response has a body: %s", result.Body.String())
+			}
+		})
+	}
+
+	result = httptest.NewRecorder()
+	ctrl.GetAll(result, httptest.NewRequest("GET", "http://localhost/things", nil), nil)
+	request := httptest.NewRequest("GET", "http://localhost/things", nil)
+	request.Header.Set("If-None-Match", result.Header().Get("ETag"))
+	result = httptest.NewRecorder()
+	ctrl.GetAll(result, request, nil)
+	if http.StatusNotModified != result.Code {
+		t.Errorf("Unchanged collection returned %d instead of %d", result.Code, http.StatusNotModified)
+	}
+}
+
+// casEntity declares its version column first to check that it is bound right before the primary key.
+type casEntity struct {
+	Revision   int64  `db:"version,version" json:"version"`
+	Identifier string `db:"id,pk" json:"id,omitempty"`
+	Name       string `db:"name" json:"name"`
+	Size       int64  `db:"size" json:"size"`
+}
+
+func (e casEntity) ID() rest.Identifier {
+	if "" == e.Identifier {
+		return nil
+	}
+	return rest.StringIdentifier(e.Identifier)
+}
+
+func (e casEntity) WithID(id rest.Identifier) (rest.IdentifiableEntity, error) {
+	e.Identifier = id.String()
+	return e, nil
+}
+
+func (e casEntity) Version() string {
+	return strconv.FormatInt(e.Revision, 10)
+}
+
+func (e casEntity) WithVersion(version string) (rest.IdentifiableEntity, error) {
+	revision, err := strconv.ParseInt(version, 10, 64)
+	e.Revision = revision
+	return e, err
+}
+
+func TestDAOCompareAndSet(t *testing.T) {
+	mapper, err := rest.NewStructMapper(casEntity{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	daos := map[string]rest.DAO{
+		"Memory":      rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{}),
+		"Database":    newSQLiteDAO(t, mapper, rest.NewMapperQueries(rest.SQLite, "things", mapper)),
+		"Transaction": newSQLiteDAO(t, mapper, transactionalQueries{rest.NewMapperQueries(rest.SQLite, "things", mapper)}),
+	}
+	for name, dao := range daos {
+		t.Run(name, func(t *testing.T) {
+			id, err := dao.Set(casEntity{Name: "created"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			expectVersion := func(expected int64) {
+				stored, err := dao.Get(id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if expected != stored.(casEntity).Revision {
+					t.Errorf("Version (%d) doesn't meet the expected result (%d)", stored.(casEntity).Revision, expected)
+				}
+			}
+			expectVersion(1)
+
+			if _, err = dao.Set(casEntity{Identifier: id.String(), Revision: 1, Name: "updated"}); nil != err {
+				t.Fatal(err)
+			}
+			expectVersion(2)
+
+			_, err = dao.Set(casEntity{Identifier: id.String(), Revision: 1, Name: "stale"})
+			if !rest.IsVersionMismatch(err) {
+				t.Errorf("Expected a version mismatch but got %+v", err)
+			}
+			expectVersion(2)
+
+			if _, err = dao.Set(casEntity{Identifier: "new", Name: "put"}); nil != err {
+				t.Fatal(err)
+			}
+			if stored, err := dao.Get(rest.StringIdentifier("new")); nil != err || 1 != stored.(casEntity).Revision {
+				t.Errorf("Entity created by ID isn't at version 1: %+v (%+v)", stored, err)
+			}
+		})
+	}
+}
+
+func TestDefaultControllerIfMatch(t *testing.T) {
+	dao := rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{})
+	id, err := dao.Set(casEntity{Name: "created"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctrl := rest.NewController("things", dao, nil, func() rest.Unmarshaller {
+		return &casUnmarshaller{}
+	})
+	params := httprouter.Params{{Key: "id", Value: id.String()}}
+	put := func(ifMatch string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest("PUT", "http://localhost/things/"+id.String(), strings.NewReader(`{"name":"updated"}`))
+		if "" != ifMatch {
+			request.Header.Set("If-Match", ifMatch)
+		}
+		result := httptest.NewRecorder()
+		ctrl.Update(result, request, params)
+		return result
+	}
+
+	if result := put(`"2"`); http.StatusPreconditionFailed != result.Code {
+		t.Errorf("Outdated If-Match returned %d instead of %d", result.Code, http.StatusPreconditionFailed)
+	}
+	result := put(`"1"`)
+	if http.StatusOK != result.Code || `"2"` != result.Header().Get("ETag") {
+		t.Errorf("Matching If-Match returned %d with ETag %s: %s", result.Code, result.Header().Get("ETag"), result.Body.String())
+	}
+	if result := put(`"1"`); http.StatusPreconditionFailed != result.Code {
+		t.Errorf("Replayed If-Match returned %d instead of %d", result.Code, http.StatusPreconditionFailed)
+	}
+
+	ctrl.RequireIfMatch = true
+	if result := put(""); http.StatusPreconditionRequired != result.Code {
+		t.Errorf("Missing If-Match returned %d instead of %d", result.Code, http.StatusPreconditionRequired)
+	}
+	request := httptest.NewRequest("DELETE", "http://localhost/things/"+id.String(), nil)
+	request.Header.Set("If-Match", "*")
+	result = httptest.NewRecorder()
+	ctrl.Delete(result, request, params)
+	if http.StatusOK != result.Code {
+		t.Errorf("Delete with If-Match returned %d: %s", result.Code, result.Body.String())
+	}
+	if result := put("*"); http.StatusPreconditionFailed != result.Code {
+		t.Errorf("If-Match on a deleted entity returned %d instead of %d", result.Code, http.StatusPreconditionFailed)
+	}
+}
+
+type casUnmarshaller struct {
+	entity casEntity
+}
+
+func (u *casUnmarshaller) UnmarshalJSON(b []byte) error {
+	return json.Unmarshal(b, &u.entity)
+}
+
+func (u *casUnmarshaller) Entity() rest.IdentifiableEntity {
+	return u.entity
+}
+
+func TestDefaultControllerIfMatchRepresentation(t *testing.T) {
+	entities := []struct {
+		name            string
+		entity          rest.IdentifiableEntity
+		newUnmarshaller rest.UnmarshallerFactory
+	}{
+		{"Hashed", testEntity{Name: "created"}, newTestUnmarshaller},
+		{"Versioned", casEntity{Name: "created"}, func() rest.Unmarshaller { return &casUnmarshaller{} }},
+	}
+	for _, entitydata := range entities {
+		t.Run(entitydata.name, func(t *testing.T) {
+			// Representations are different, so are their strong ETags
+			etags := make(map[string]string)
+			for _, accept := range []string{"", rest.XMLMediaType, rest.YAMLMediaType, rest.MessagePackMediaType} {
+				t.Run("Read as '"+accept+"'", func(t *testing.T) {
+					dao := rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{})
+					id, err := dao.Set(entitydata.entity)
+					if err != nil {
+						t.Fatal(err)
+					}
+					ctrl := rest.NewController("things", dao, nil, entitydata.newUnmarshaller)
+					params := httprouter.Params{{Key: "id", Value: id.String()}}
+					request := httptest.NewRequest("GET", "http://localhost/things/"+id.String(), nil)
+					request.Header.Set("Accept", accept)
+					result := httptest.NewRecorder()
+					ctrl.Get(result, request, params)
+					etag := result.Header().Get("ETag")
+					if other, found := etags[etag]; found {
+						t.Errorf("ETag %s of '%s' is also the one of '%s'", etag, accept, other)
+					}
+					etags[etag] = accept
+
+					for _, expected := range []int{http.StatusOK, http.StatusPreconditionFailed} {
+						request := httptest.NewRequest("PUT", "http://localhost/things/"+id.String(), strings.NewReader(`{"name":"updated"}`))
+						request.Header.Set("If-Match", etag)
+						result = httptest.NewRecorder()
+						ctrl.Update(result, request, params)
+						if expected != result.Code {
+							t.Errorf("Update with If-Match %s returned %d instead of %d", etag, result.Code, expected)
+						}
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestDefaultControllerWithoutIfMatch(t *testing.T) {
+	mapper, err := rest.NewStructMapper(casEntity{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	daos := map[string]rest.DAO{
+		"Memory":   rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{}),
+		"Database": newSQLiteDAO(t, mapper, rest.NewMapperQueries(rest.SQLite, "things", mapper)),
+	}
+	for name, dao := range daos {
+		t.Run(name, func(t *testing.T) {
+			id, err := dao.Set(casEntity{Name: "created"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctrl := rest.NewController("things", dao, nil, func() rest.Unmarshaller {
+				return &casUnmarshaller{}
+			})
+			params := httprouter.Params{{Key: "id", Value: id.String()}}
+
+			for i, expected := range []string{`"2"`, `"3"`} {
+				request := httptest.NewRequest("PUT", "http://localhost/things/"+id.String(), strings.NewReader(`{"name":"updated-`+strconv.Itoa(i)+`"}`))
+				result := httptest.NewRecorder()
+				ctrl.Update(result, request, params)
+				if http.StatusOK != result.Code || expected != result.Header().Get("ETag") {
+					t.Errorf("Update without If-Match returned %d with ETag %s instead of %s: %s", result.Code, result.Header().Get("ETag"), expected, result.Body.String())
+				}
+			}
+
+			request := httptest.NewRequest("PATCH", "http://localhost/things/"+id.String(), strings.NewReader(`{"name":"patched","version":0}`))
+			request.Header.Set("Content-Type", rest.MergePatchMediaType)
+			result := httptest.NewRecorder()
+			ctrl.Patch(result, request, params)
+			if http.StatusOK != result.Code || `"4"` != result.Header().Get("ETag") {
+				t.Errorf("Patch without If-Match returned %d with ETag %s: %s", result.Code, result.Header().Get("ETag"), result.Body.String())
+			}
+
+			request = httptest.NewRequest("PUT", "http://localhost/things/new", strings.NewReader(`{"name":"put"}`))
+			result = httptest.NewRecorder()
+			ctrl.Update(result, request, httprouter.Params{{Key: "id", Value: "new"}})
+			if http.StatusOK != result.Code || `"1"` != result.Header().Get("ETag") {
+				t.Errorf("Creation without If-Match returned %d with ETag %s: %s", result.Code, result.Header().Get("ETag"), result.Body.String())
+			}
+
+			ctrl.RequireIfMatch = true
+			request = httptest.NewRequest("PUT", "http://localhost/things/"+id.String(), strings.NewReader(`{"name":"required"}`))
+			result = httptest.NewRecorder()
+			ctrl.Update(result, request, params)
+			if http.StatusPreconditionRequired != result.Code {
+				t.Errorf("Update without required If-Match returned %d instead of %d", result.Code, http.StatusPreconditionRequired)
+			}
+		})
+	}
+}
@@ -1,12 +1,16 @@
 package rest
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"net/url"
-	"strconv"
+	"reflect"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/pkg/errors"
@@ -23,6 +27,9 @@ type Unmarshaller interface {
 	Entity() IdentifiableEntity
 }
 
+// UnmarshallerFactory returns a fresh decoding target, so concurrent requests never share state.
+type UnmarshallerFactory func() Unmarshaller
+
 type ErrorHandler interface {
 	Handle(w http.ResponseWriter, err error) error
 }
@@ -34,8 +41,20 @@ type DefaultController struct {
 	basePath         string
 	ErrorHandler     ErrorHandler
 	Logger           Logger
-	Unmarshaller     Unmarshaller
+	NewUnmarshaller  UnmarshallerFactory
 	MiddlewareSetter MiddlewareSetter
+	// FilterableFields and SortableFields enable filtering and sorting of GetAll. DAO must then implement FilterableDAO.
+	FilterableFields []string
+	SortableFields   []string
+	// Cursors switches GetAll to keyset pagination with signed `after`/`before` cursors instead of offsets.
+	Cursors *CursorCodec
+	// BareCollections makes GetAll answer with the items array only. Navigation stays available through the Link header.
+	BareCollections bool
+	// RequireIfMatch makes PUT, PATCH and DELETE answer 428 Precondition Required when the If-Match header is missing.
+	RequireIfMatch bool
+	// Codecs encode responses and decode request bodies. DefaultCodecs are used when nil.
+	Codecs   *Codecs
+	registry *Registry
 }
 
 const keyIdentifier = "id"
@@ -46,12 +65,15 @@ func (s StringIdentifier) String() string {
 	return string(s)
 }
 
-func NewController(basePath string, dao DAO, errorHandler ErrorHandler, unmarshaller Unmarshaller) *DefaultController {
+func NewController(basePath string, dao DAO, errorHandler ErrorHandler, newUnmarshaller UnmarshallerFactory) *DefaultController {
+	if nil == errorHandler {
+		errorHandler = ProblemErrorHandler{}
+	}
 	return &DefaultController{
-		DAO:          dao,
-		basePath:     basePath,
-		ErrorHandler: errorHandler,
-		Unmarshaller: unmarshaller,
+		DAO:             dao,
+		basePath:        basePath,
+		ErrorHandler:    errorHandler,
+		NewUnmarshaller: newUnmarshaller,
 	}
 }
 
@@ -59,12 +81,59 @@ func (c DefaultController) BasePath() string {
 	return c.basePath
 }
 
+// BindRegistry registers the controller DAO and uses the registry to expand relations of its entities.
+func (c *DefaultController) BindRegistry(registry *Registry) {
+	c.registry = registry
+	registry.Add(c.basePath, c.DAO)
+}
+
 func (c *DefaultController) Routes() []Route {
+	entity := c.entityPrototype()
+	collection := CollectionOf{Item: entity, Bare: c.BareCollections}
+	entityMediaTypes := c.codecs().encoderMediaTypes(entity)
+	bodyMediaTypes := c.codecs().decoderMediaTypes()
+	ifMatch := Parameter{Name: "If-Match", In: "header", Required: c.RequireIfMatch, Description: "ETag of the entity being modified", Schema: Schema{"type": "string"}}
 	defaultRoutes := []Route{
-		NewRoute(GET, Path("/"+c.basePath), c.GetAll),
-		NewRoute(GET, Path("/"+c.basePath+"/:"+keyIdentifier), c.Get),
-		NewRoute(PUT, Path("/"+c.basePath), c.Update),
-		NewRoute(DELETE, Path("/"+c.basePath+"/:"+keyIdentifier), c.Delete),
+		NewRoute(GET, Path("/"+c.basePath), c.GetAll).Describe(RouteMetadata{
+			Summary:            "List " + c.basePath,
+			Parameters:         c.collectionParameters(),
+			Response:           collection,
+			ResponseMediaTypes: c.codecs().encoderMediaTypes(&CollectionResponse{}),
+		}),
+		NewRoute(GET, Path("/"+c.basePath+"/:"+keyIdentifier), c.Get).Describe(RouteMetadata{
+			Summary:            "Get one of " + c.basePath,
+			Parameters:         []Parameter{expandParameter, fieldsParameter},
+			Response:           entity,
+			ResponseMediaTypes: entityMediaTypes,
+		}),
+		NewRoute(POST, Path("/"+c.basePath), c.Create).Describe(RouteMetadata{
+			Summary:            "Create one of " + c.basePath,
+			Request:            entity,
+			RequestMediaTypes:  bodyMediaTypes,
+			Response:           entity,
+			ResponseMediaTypes: entityMediaTypes,
+			Status:             http.StatusCreated,
+		}),
+		NewRoute(PUT, Path("/"+c.basePath+"/:"+keyIdentifier), c.Update).Describe(RouteMetadata{
+			Summary:            "Replace one of " + c.basePath,
+			Parameters:         []Parameter{ifMatch},
+			Request:            entity,
+			RequestMediaTypes:  bodyMediaTypes,
+			Response:           entity,
+			ResponseMediaTypes: entityMediaTypes,
+		}),
+		NewRoute(PATCH, Path("/"+c.basePath+"/:"+keyIdentifier), c.Patch).Describe(RouteMetadata{
+			Summary:            "Patch one of " + c.basePath,
+			Parameters:         []Parameter{ifMatch},
+			Request:            Schema{},
+			RequestMediaTypes:  []string{MergePatchMediaType, JSONPatchMediaType},
+			Response:           entity,
+			ResponseMediaTypes: entityMediaTypes,
+		}),
+		NewRoute(DELETE, Path("/"+c.basePath+"/:"+keyIdentifier), c.Delete).Describe(RouteMetadata{
+			Summary:    "Delete one of " + c.basePath,
+			Parameters: []Parameter{ifMatch},
+		}),
 	}
 
 	if nil == c.MiddlewareSetter {
@@ -73,11 +142,51 @@ func (c *DefaultController) Routes() []Route {
 
 	routesWithMiddlewares := make([]Route, 0)
 	for _, route := range defaultRoutes {
-		routesWithMiddlewares = append(routesWithMiddlewares, NewRoute(route.Method(), route.Path(), c.MiddlewareSetter(route.Method(), route.Path(), route.Handler())))
+		routesWithMiddlewares = append(routesWithMiddlewares, NewRoute(route.Method(), route.Path(), c.MiddlewareSetter(route.Method(), route.Path(), route.Handler())).Describe(MetadataOf(route)))
 	}
 	return routesWithMiddlewares
 }
 
+var (
+	expandParameter = Parameter{Name: "expand", In: "query", Description: "'true' to embed entities instead of their URLs, or a comma separated list of relations to embed", Schema: Schema{"type": "string"}}
+	fieldsParameter = Parameter{Name: "fields", In: "query", Description: "Comma separated list of the properties to return", Schema: Schema{"type": "string"}}
+)
+
+// collectionParameters documents the pagination, expansion, projection, sort and filter parameters of GetAll.
+func (c *DefaultController) collectionParameters() []Parameter {
+	parameters := make([]Parameter, 0)
+	if nil == c.Cursors {
+		parameters = append(parameters, Parameter{Name: "offset", In: "query", Description: "Index of the first item", Schema: Schema{"type": "integer", "minimum": 0}})
+	} else {
+		parameters = append(parameters,
+			Parameter{Name: "after", In: "query", Description: "Cursor of the item preceding the page", Schema: Schema{"type": "string"}},
+			Parameter{Name: "before", In: "query", Description: "Cursor of the item following the page", Schema: Schema{"type": "string"}},
+		)
+	}
+	parameters = append(parameters,
+		Parameter{Name: "limit", In: "query", Description: "Maximum number of items", Schema: Schema{"type": "integer", "minimum": 0}},
+		expandParameter,
+		fieldsParameter,
+	)
+	if 0 != len(c.SortableFields) {
+		parameters = append(parameters, Parameter{Name: "sort", In: "query", Description: "Comma separated list of " + strings.Join(c.SortableFields, ", ") + ", prefixed by '-' for a descending order", Schema: Schema{"type": "string"}})
+	}
+	for _, field := range c.FilterableFields {
+		parameters = append(parameters, Parameter{Name: field, In: "query", Description: "Keeps items whose " + field + " equals the value. " + field + "[ne|lt|lte|gt|gte|in|prefix] use other operators", Schema: Schema{"type": "string"}})
+	}
+	return parameters
+}
+
+// entityPrototype returns the entity decoded by NewUnmarshaller to describe it, or a generic object schema.
+func (c *DefaultController) entityPrototype() interface{} {
+	if nil != c.NewUnmarshaller {
+		if entity := c.NewUnmarshaller().Entity(); nil != entity {
+			return entity
+		}
+	}
+	return Schema{"type": "object"}
+}
+
 func (c *DefaultController) GetAll(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	params := r.URL.Query()
 
@@ -87,14 +196,47 @@ func (c *DefaultController) GetAll(w http.ResponseWriter, r *http.Request, _ htt
 		return
 	}
 
-	var expand bool
-	expandStr := params.Get("expand")
-	if "" != expandStr {
-		expand, err = strconv.ParseBool(expandStr)
+	expand, relations, err := ParseExpand(params)
+	if err != nil {
+		c.Handle(w, errors.Wrapf(err, "Load expand from query params"))
+		return
+	}
+	fields, err := c.loadFields(params, relations)
+	if err != nil {
+		c.Handle(w, errors.Wrapf(err, "Load fields from query params"))
+		return
+	}
+
+	query := Query{Pagination: pagination}
+	if 0 != len(c.FilterableFields) {
+		query.Filter, err = ParseFilter(params, c.FilterableFields)
+		if err != nil {
+			c.Handle(w, errors.Wrapf(err, "Load filter from query params"))
+			return
+		}
+	}
+	if 0 != len(c.SortableFields) {
+		query.Sort, err = ParseSort(params, c.SortableFields)
+		if err != nil {
+			c.Handle(w, errors.Wrapf(err, "Load sort from query params"))
+			return
+		}
+	}
+	if nil != c.Cursors {
+		keyset, err := loadKeyset(params, c.Cursors)
 		if err != nil {
-			c.Handle(w, errors.Wrapf(err, "Parsing expand flag from '%s'", expandStr))
+			c.Handle(w, errors.Wrapf(err, "Load cursor from query params"))
+			return
+		}
+		query.Keyset = &keyset
+		if err = query.validate(); nil != err {
+			c.Handle(w, err)
 			return
 		}
+		// Load one more item to know if another page follows
+		if pagination.Limit() < math.MaxInt64 {
+			query.Pagination.SetLimit(pagination.Limit() + 1)
+		}
 	}
 
 	baseURL, err := getBaseURL(r)
@@ -102,83 +244,132 @@ func (c *DefaultController) GetAll(w http.ResponseWriter, r *http.Request, _ htt
 		c.Handle(w, errors.Wrapf(err, "Constructing base url from request"))
 		return
 	}
-	var items []interface{}
+	items, keys, err := c.loadItems(r.Context(), query, expand, relations, *baseURL)
+	if err != nil {
+		c.Handle(w, errors.Wrapf(err, "Get all items {offset:%+v;limit:%+v}", pagination.Offset(), pagination.Limit()))
+		return
+	}
+	var page CursorPage
+	if nil != query.Keyset {
+		items, page = cursorPage(*query.Keyset, pagination.Limit(), items, keys)
+	}
 	if expand {
-		entities, err := c.DAO.GetAllEntities(pagination)
-		if err != nil {
-			c.Handle(w, errors.Wrapf(err, "Get all entities {offset:%+v;limit:%+v}", pagination.Offset, pagination.Limit))
-			return
-		}
-		for _, entity := range entities {
-			items = append(items, entity)
-		}
-	} else {
-		ids, err := c.DAO.GetAllIDs(pagination)
-		if err != nil {
-			c.Handle(w, errors.Wrapf(err, "Get all ids {offset:%+v;limit:%+v}", pagination.Offset, pagination.Limit))
-		}
-		for _, id := range ids {
-			items = append(items, baseURL.String()+"/"+id.String())
+		for i, item := range items {
+			if items[i], err = fields.Apply(item); nil != err {
+				c.Handle(w, errors.Wrapf(err, "Selecting fields of '%+v'", item))
+				return
+			}
 		}
 	}
 
-	nbEntity, err := c.DAO.TotalNumberOfEntities()
+	nbEntity, err := c.totalNumberOfEntities(r.Context(), query.Filter)
 	if err != nil {
 		c.Handle(w, errors.Wrapf(err, "Get total number of entities"))
 		return
 	}
 
 	respBuilder := &CollectionResponseBuilder{}
+	if nil != c.Cursors {
+		respBuilder.WithCursors(c.Cursors, page)
+	}
 	response, err := respBuilder.WithBaseURI(*baseURL).WithItems(items).WithMaxNumberOfItems(nbEntity).WithQueryParams(params).Build()
 	if err != nil {
 		c.Handle(w, errors.Wrapf(err, "Building collection response"))
 		return
 	}
-	responseBytes, err := json.Marshal(response)
+	var body interface{} = response
+	if c.BareCollections {
+		body = response.Items
+		if nil == response.Items {
+			body = []interface{}{}
+		}
+	}
+	mediaType, responseBytes, err := c.codecs().encode(r.Header.Get("Accept"), body)
 	if err != nil {
 		c.Handle(w, errors.Wrapf(err, "Encoding response '%+v'", response))
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	fmt.Fprintf(w, string(responseBytes))
+	response.WriteHeaders(w.Header())
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Add("Vary", "Accept")
+	etag := ETag(body, mediaType, responseBytes)
+	writeValidators(w.Header(), etag, time.Time{})
+	if notModified(r, etag, time.Time{}) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if _, err = w.Write(responseBytes); nil != err {
+		c.log(errors.Wrapf(err, "Writing collection as response '%s'", string(responseBytes)).Error())
+	}
 }
 
 func (c *DefaultController) Get(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	id := params.ByName("id")
-	entity, err := c.DAO.Get(StringIdentifier(id))
+	_, relations, err := ParseExpand(r.URL.Query())
 	if err != nil {
-		c.Handle(w, errors.Wrapf(err, "Get entity with id '%+v'", id))
+		c.Handle(w, errors.Wrapf(err, "Load expand from query params"))
 		return
 	}
-	jsonEntity, err := json.Marshal(entity)
+	fields, err := c.loadFields(r.URL.Query(), relations)
 	if err != nil {
-		c.Handle(w, errors.Wrapf(err, "Encoding entity into json '%+v'", entity))
+		c.Handle(w, errors.Wrapf(err, "Load fields from query params"))
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	_, err = fmt.Fprint(w, string(jsonEntity))
+	entity, err := c.get(r.Context(), StringIdentifier(id))
 	if err != nil {
-		c.Handle(w, errors.Wrapf(err, "Writing entity as response '%s'", string(jsonEntity)))
+		c.Handle(w, errors.Wrapf(err, "Get entity with id '%+v'", id))
 		return
 	}
-	return
+	var expanded interface{} = entity
+	if 0 != len(relations) {
+		representations, err := c.expander().expand(r.Context(), []Entity{entity}, relations)
+		if err != nil {
+			c.Handle(w, errors.Wrapf(err, "Expanding relations of '%+v'", id))
+			return
+		}
+		expanded = representations[0]
+	}
+	representation, err := fields.Apply(expanded)
+	if err != nil {
+		c.Handle(w, errors.Wrapf(err, "Selecting fields of '%+v'", entity))
+		return
+	}
+	// Versions and modification times only describe the entity as stored, not its projections
+	var validated Entity = entity
+	if 0 != len(relations) || nil != fields {
+		validated = representation
+	}
+	c.writeRepresentation(w, r, http.StatusOK, validated, representation)
 }
 
-func (c *DefaultController) Update(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	// Copy EmptyInstance to not modify it and only use it as reference
+func (c *DefaultController) Create(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	bodyBytes, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		c.Handle(w, errors.Wrapf(err, "Reading body"))
 		return
 	}
-	err = json.Unmarshal(bodyBytes, c.Unmarshaller)
+	entity, err := c.decodeBody(r.Header.Get("Content-Type"), bodyBytes)
 	if err != nil {
-		c.Handle(w, errors.Wrapf(err, "Unmarshal %s", string(bodyBytes)))
+		c.Handle(w, err)
+		return
+	}
+	if hasID(entity) {
+		c.Handle(w, NewError(KindValidation, nil, "ID '%s' cannot be provided when creating an entity", entity.ID()))
 		return
 	}
-	id, err := c.DAO.Set(c.Unmarshaller.Entity())
+	id, err := c.dao().SetContext(r.Context(), entity)
 	if err != nil {
-		c.Handle(w, errors.Wrapf(err, "Update '%+v'", c.Unmarshaller.Entity()))
+		c.Handle(w, errors.Wrapf(err, "Create '%+v'", entity))
+		return
+	}
+	identified, err := entity.WithID(id)
+	if err != nil {
+		c.Handle(w, errors.Wrapf(err, "Setting generated ID '%s'", id))
+		return
+	}
+	created, err := c.stored(r.Context(), identified)
+	if err != nil {
+		c.Handle(w, errors.Wrapf(err, "Get created entity '%s'", id))
 		return
 	}
 	baseURL, err := getBaseURL(r)
@@ -186,15 +377,131 @@ func (c *DefaultController) Update(w http.ResponseWriter, r *http.Request, _ htt
 		c.Handle(w, errors.Wrapf(err, "Get base request url from request"))
 		return
 	}
-	baseURLStr := baseURL.String()
-	idStr := id.String()
-	fmt.Fprintf(w, baseURLStr+"/%s", idStr)
-	return
+	w.Header().Set("Location", baseURL.String()+"/"+id.String())
+	c.writeEntity(w, r, http.StatusCreated, created)
+}
+
+func (c *DefaultController) Update(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	id := params.ByName(keyIdentifier)
+	bodyBytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		c.Handle(w, errors.Wrapf(err, "Reading body"))
+		return
+	}
+	entity, err := c.decodeBody(r.Header.Get("Content-Type"), bodyBytes)
+	if err != nil {
+		c.Handle(w, err)
+		return
+	}
+	if hasID(entity) && entity.ID().String() != id {
+		c.Handle(w, NewError(KindValidation, nil, "Body ID '%s' doesn't match path ID '%s'", entity.ID(), id))
+		return
+	}
+	entity, err = entity.WithID(StringIdentifier(id))
+	if err != nil {
+		c.Handle(w, errors.Wrapf(err, "Setting ID '%s'", id))
+		return
+	}
+	var stored Entity
+	err = InTransaction(r.Context(), c.DAO, func(ctx context.Context) error {
+		current, err := c.checkIfMatch(ctx, r, entity.ID())
+		if err != nil {
+			return err
+		}
+		if nil == current {
+			if current, err = c.storedVersioned(ctx, entity); nil != err {
+				return err
+			}
+		}
+		// Compare-and-set against the version the client matched, or the stored one without If-Match
+		if versioned, ok := current.(Versioned); ok {
+			if entity, err = withVersion(entity, versioned.Version()); nil != err {
+				return err
+			}
+		}
+		if _, err = c.dao().SetContext(ctx, entity); err != nil {
+			return errors.Wrapf(err, "Update '%+v'", entity)
+		}
+		stored, err = c.stored(ctx, entity)
+		return err
+	})
+	if err != nil {
+		c.Handle(w, err)
+		return
+	}
+	c.writeEntity(w, r, http.StatusOK, stored)
+}
+
+func (c *DefaultController) Patch(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	id := params.ByName(keyIdentifier)
+	patchBytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		c.Handle(w, errors.Wrapf(err, "Reading body"))
+		return
+	}
+	var entity Entity
+	err = InTransaction(r.Context(), c.DAO, func(ctx context.Context) error {
+		if _, err := c.checkIfMatch(ctx, r, StringIdentifier(id)); nil != err {
+			return err
+		}
+		var err error
+		entity, err = c.patch(ctx, StringIdentifier(id), r.Header.Get("Content-Type"), patchBytes)
+		return err
+	})
+	if err != nil {
+		if _, ok := errors.Cause(err).(UnsupportedPatchError); ok {
+			w.Header().Set("Accept-Patch", MergePatchMediaType+", "+JSONPatchMediaType)
+		}
+		c.Handle(w, errors.Wrapf(err, "Patching entity '%s'", id))
+		return
+	}
+	c.writeEntity(w, r, http.StatusOK, entity)
+}
+
+func (c *DefaultController) patch(ctx context.Context, id Identifier, contentType string, patch []byte) (Entity, error) {
+	current, err := c.get(ctx, id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Get entity with id '%+v'", id)
+	}
+	currentBytes, err := json.Marshal(current)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Encoding entity into json '%+v'", current)
+	}
+	patchedBytes, err := applyPatch(contentType, currentBytes, patch)
+	if err != nil {
+		return nil, err
+	}
+	entity, err := c.decode(patchedBytes)
+	if err != nil {
+		return nil, NewError(KindValidation, err, "Patched entity is invalid")
+	}
+	if hasID(entity) && entity.ID().String() != id.String() {
+		return nil, NewError(KindValidation, nil, "Patched ID '%s' doesn't match path ID '%s'", entity.ID(), id)
+	}
+	entity, err = entity.WithID(id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Setting ID '%s'", id)
+	}
+	// Versions aren't part of patches, even when they are part of representations
+	if versioned, ok := current.(Versioned); ok {
+		if entity, err = withVersion(entity, versioned.Version()); nil != err {
+			return nil, err
+		}
+	}
+	if _, err = c.dao().SetContext(ctx, entity); err != nil {
+		return nil, errors.Wrapf(err, "Patch '%+v'", entity)
+	}
+	return c.stored(ctx, entity)
 }
 
 func (c *DefaultController) Delete(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	id := params.ByName("id")
-	err := c.DAO.Delete(StringIdentifier(id))
+	err := InTransaction(r.Context(), c.DAO, func(ctx context.Context) error {
+		if _, err := c.checkIfMatch(ctx, r, StringIdentifier(id)); nil != err {
+			return err
+		}
+		return c.dao().DeleteContext(ctx, StringIdentifier(id))
+	})
 	if err != nil {
 		c.Handle(w, errors.Wrapf(err, "Deleting %s", id))
 		return
@@ -202,12 +509,296 @@ func (c *DefaultController) Delete(w http.ResponseWriter, r *http.Request, param
 	return
 }
 
+// checkIfMatch compares the If-Match header with the ETag of the current entity, returned when the header is present.
+func (c *DefaultController) checkIfMatch(ctx context.Context, r *http.Request, id Identifier) (Entity, error) {
+	ifMatch := r.Header.Get("If-Match")
+	if "" == ifMatch {
+		if c.RequireIfMatch {
+			return nil, NewError(KindPreconditionRequired, nil, "If-Match header is required to modify '%s'", id)
+		}
+		return nil, nil
+	}
+	current, err := c.get(ctx, id)
+	if IsNotFound(err) {
+		return nil, NewError(KindPreconditionFailed, err, "'%s' doesn't exist", id)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "Get entity with id '%+v'", id)
+	}
+	// The client may have read any representation, each having its own ETag
+	etags, err := c.representationETags(current)
+	if err != nil {
+		return nil, err
+	}
+	for _, etag := range etags {
+		if matchesETag(ifMatch, etag, false) {
+			return current, nil
+		}
+	}
+	return nil, NewError(KindPreconditionFailed, nil, "'%s' has been modified", id)
+}
+
+// representationETags returns the ETag of every representation of entity the codecs can send.
+func (c *DefaultController) representationETags(entity Entity) ([]string, error) {
+	mediaTypes := c.codecs().encoderMediaTypes(entity)
+	etags := make([]string, 0, len(mediaTypes))
+	// Versions are enough to tag versioned entities
+	versioned, ok := entity.(Versioned)
+	hashed := !ok || "" == versioned.Version()
+	for _, mediaType := range mediaTypes {
+		var encoded []byte
+		if hashed {
+			var err error
+			if _, encoded, err = c.codecs().encode(mediaType, entity); nil != err {
+				return nil, errors.Wrapf(err, "Encoding entity '%+v' as %s", entity, mediaType)
+			}
+		}
+		etags = append(etags, ETag(entity, mediaType, encoded))
+	}
+	return etags, nil
+}
+
+// storedVersioned returns the stored entity when entity is a VersionedEntity, nil when it isn't or doesn't exist yet.
+func (c *DefaultController) storedVersioned(ctx context.Context, entity IdentifiableEntity) (Entity, error) {
+	if _, ok := entity.(VersionedEntity); !ok {
+		return nil, nil
+	}
+	current, err := c.get(ctx, entity.ID())
+	if IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "Get entity with id '%+v'", entity.ID())
+	}
+	return current, nil
+}
+
+// stored returns the entity as saved: VersionedEntity are reloaded to get the version set by the DAO.
+func (c *DefaultController) stored(ctx context.Context, entity IdentifiableEntity) (Entity, error) {
+	if _, ok := entity.(VersionedEntity); !ok {
+		return entity, nil
+	}
+	return c.get(ctx, entity.ID())
+}
+
+func (c *DefaultController) dao() ContextDAO {
+	return AsContextDAO(c.DAO)
+}
+
+func (c *DefaultController) expander() expander {
+	return expander{registry: c.registry}
+}
+
+// loadItems returns the items of the collection, either entities (with their relations expanded) or their URLs, with their keys.
+func (c *DefaultController) loadItems(ctx context.Context, query Query, expand bool, relations Expansion, baseURL url.URL) ([]interface{}, []string, error) {
+	items := make([]interface{}, 0)
+	keys := make([]string, 0)
+	if expand {
+		entities, err := c.getAllEntities(ctx, query)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, entity := range entities {
+			items = append(items, entity)
+			if identifiable, ok := entity.(IdentifiableEntity); ok {
+				keys = append(keys, identifiable.ID().String())
+			}
+		}
+		if 0 != len(relations) {
+			if items, err = c.expander().expand(ctx, entities, relations); nil != err {
+				return nil, nil, errors.Wrapf(err, "Expanding relations")
+			}
+		}
+	} else {
+		ids, err := c.getAllIDs(ctx, query)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, id := range ids {
+			items = append(items, baseURL.String()+"/"+id.String())
+			keys = append(keys, id.String())
+		}
+	}
+	if nil != query.Keyset && len(keys) != len(items) {
+		return nil, nil, fmt.Errorf("Entities must implement IdentifiableEntity to be paginated with cursors")
+	}
+	return items, keys, nil
+}
+
+// cursorPage drops the extra item loaded to detect a following page and describes the page boundaries.
+func cursorPage(keyset Keyset, limit int64, items []interface{}, keys []string) ([]interface{}, CursorPage) {
+	hasMore := int64(len(items)) > limit
+	if hasMore {
+		if keyset.Backward {
+			items = items[int64(len(items))-limit:]
+			keys = keys[int64(len(keys))-limit:]
+		} else {
+			items = items[:limit]
+			keys = keys[:limit]
+		}
+	}
+	page := CursorPage{FirstKey: keyset.Key, LastKey: keyset.Key}
+	if 0 != len(keys) {
+		page.FirstKey = keys[0]
+		page.LastKey = keys[len(keys)-1]
+	}
+	if keyset.Backward {
+		page.HasPrevious = hasMore
+		page.HasNext = "" != keyset.Key
+	} else {
+		page.HasPrevious = "" != keyset.Key
+		page.HasNext = hasMore
+	}
+	return items, page
+}
+
+func (c *DefaultController) filterableDAO() (FilterableDAO, error) {
+	filterable, ok := c.DAO.(FilterableDAO)
+	if !ok {
+		return nil, fmt.Errorf("DAO %T doesn't support filtering nor sorting", c.DAO)
+	}
+	return filterable, nil
+}
+
+func (c *DefaultController) getAllEntities(ctx context.Context, query Query) ([]Entity, error) {
+	if query.isDefault() {
+		return c.dao().GetAllEntitiesContext(ctx, query.Pagination)
+	}
+	filterable, err := c.filterableDAO()
+	if err != nil {
+		return nil, err
+	}
+	return filterable.GetAllEntitiesMatching(ctx, query)
+}
+
+func (c *DefaultController) getAllIDs(ctx context.Context, query Query) ([]Identifier, error) {
+	if query.isDefault() {
+		return c.dao().GetAllIDsContext(ctx, query.Pagination)
+	}
+	filterable, err := c.filterableDAO()
+	if err != nil {
+		return nil, err
+	}
+	return filterable.GetAllIDsMatching(ctx, query)
+}
+
+func (c *DefaultController) totalNumberOfEntities(ctx context.Context, filter Filter) (int64, error) {
+	if 0 == len(filter) {
+		return c.dao().TotalNumberOfEntitiesContext(ctx)
+	}
+	filterable, err := c.filterableDAO()
+	if err != nil {
+		return 0, err
+	}
+	return filterable.TotalNumberOfEntitiesMatching(ctx, filter)
+}
+
+func (c *DefaultController) get(ctx context.Context, id Identifier) (Entity, error) {
+	entity, err := c.dao().GetContext(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if nil == entity {
+		return nil, NotFoundError{ID: id}
+	}
+	return entity, nil
+}
+
+func (c *DefaultController) codecs() *Codecs {
+	if nil == c.Codecs {
+		return defaultCodecs
+	}
+	return c.Codecs
+}
+
+// decodeBody reads a request body through the decoder of its content type. Non JSON bodies are converted to JSON for the Unmarshaller.
+func (c *DefaultController) decodeBody(contentType string, body []byte) (IdentifiableEntity, error) {
+	decoder, err := c.codecs().Decoder(contentType)
+	if err != nil {
+		return nil, err
+	}
+	if _, isJSON := decoder.(JSONCodec); !isJSON {
+		var decoded interface{}
+		if err = decoder.Decode(bytes.NewReader(body), &decoded); nil != err {
+			return nil, NewError(KindValidation, err, "Invalid request body")
+		}
+		if body, err = json.Marshal(decoded); nil != err {
+			return nil, NewError(KindValidation, err, "Invalid request body")
+		}
+	}
+	entity, err := c.decode(body)
+	if err != nil {
+		return nil, NewError(KindValidation, err, "Invalid request body")
+	}
+	return entity, nil
+}
+
+func (c *DefaultController) decode(body []byte) (IdentifiableEntity, error) {
+	unmarshaller := c.NewUnmarshaller()
+	if err := json.Unmarshal(body, unmarshaller); nil != err {
+		return nil, err
+	}
+	return unmarshaller.Entity(), nil
+}
+
+// loadFields parses the requested fieldset, validated against the entity type when NewUnmarshaller is set.
+// Sub fields of expanded relations are left to the related entities.
+func (c *DefaultController) loadFields(params url.Values, expansion Expansion) (Fieldset, error) {
+	fields, err := ParseFields(params)
+	if err != nil || nil == fields || nil == c.NewUnmarshaller {
+		return fields, err
+	}
+	prototype := c.NewUnmarshaller().Entity()
+	entityType := reflect.TypeOf(prototype)
+	if nil == entityType {
+		return fields, nil
+	}
+	validated := fields
+	if related, ok := prototype.(RelatedEntity); ok && 0 != len(expansion) {
+		validated = fields.withoutExpandedRelations(related.Relations(), expansion, "")
+	}
+	if err = validated.Validate(entityType); nil != err {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func (c *DefaultController) writeEntity(w http.ResponseWriter, r *http.Request, status int, entity Entity) {
+	c.writeRepresentation(w, r, status, entity, entity)
+}
+
+// writeRepresentation encodes representation with validators computed from entity, and answers 304 when the client copy is fresh.
+func (c *DefaultController) writeRepresentation(w http.ResponseWriter, r *http.Request, status int, entity Entity, representation interface{}) {
+	mediaType, encoded, err := c.codecs().encode(r.Header.Get("Accept"), representation)
+	if err != nil {
+		c.Handle(w, errors.Wrapf(err, "Encoding entity '%+v'", representation))
+		return
+	}
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Add("Vary", "Accept")
+	etag, modified := ETag(entity, mediaType, encoded), lastModified(entity)
+	writeValidators(w.Header(), etag, modified)
+	if http.StatusOK == status && notModified(r, etag, modified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(status)
+	_, err = w.Write(encoded)
+	if err != nil {
+		c.log(errors.Wrapf(err, "Writing entity as response '%s'", string(encoded)).Error())
+	}
+}
+
 func (c *DefaultController) Handle(w http.ResponseWriter, err error) {
-	e := c.ErrorHandler.Handle(w, err)
+	errorHandler := c.ErrorHandler
+	if nil == errorHandler {
+		errorHandler = ProblemErrorHandler{}
+	}
+	e := errorHandler.Handle(w, err)
 	if nil != e {
 		newErr := errors.Wrapf(e, "Error while writing error response -{ %s }-", err.Error())
 		c.log(newErr.Error())
-		fmt.Fprintf(w, newErr.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 	} else {
 		c.log(err.Error())
 	}
@@ -220,7 +811,7 @@ func (c *DefaultController) log(msg string, objects ...interface{}) {
 }
 
 func getBaseURL(r *http.Request) (*url.URL, error) {
-	return url.Parse("http://" + r.Host + r.URL.Path)
+	return url.Parse(BaseURL(r).String() + r.URL.Path)
 }
 
 type CORSController struct {
@@ -238,13 +829,20 @@ func NewCORSController(controller Controller, allowedOrigin string) *CORSControl
 
 func (c *CORSController) Routes() []Route {
 	routes := c.Controller.Routes()
-	optRoute := NewRoute(OPTIONS, Path("/"+c.BasePath()), c.Options)
+	optRoute := NewRoute(OPTIONS, Path("/"+c.BasePath()), c.Options).Describe(RouteMetadata{Summary: "Allowed methods and CORS policy of " + c.BasePath()})
 	if nil != c.MiddlewareSetter {
-		optRoute = NewRoute(optRoute.Method(), optRoute.Path(), c.MiddlewareSetter(optRoute.Method(), optRoute.Path(), optRoute.handler))
+		optRoute = NewRoute(optRoute.Method(), optRoute.Path(), c.MiddlewareSetter(optRoute.Method(), optRoute.Path(), optRoute.handler)).Describe(optRoute.Metadata())
 	}
 	return append(routes, optRoute)
 }
 
+// BindRegistry forwards the registry to the wrapped controller.
+func (c *CORSController) BindRegistry(registry *Registry) {
+	if binder, ok := c.Controller.(RegistryBinder); ok {
+		binder.BindRegistry(registry)
+	}
+}
+
 func (c CORSController) Options(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	routes := c.Routes()
 	methods := make([]string, 0)
@@ -0,0 +1,363 @@
+package rest_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/normegil/rest"
+)
+
+type testEntity struct {
+	Identifier string `json:"id,omitempty"`
+	Name       string `json:"name"`
+}
+
+func (e testEntity) ID() rest.Identifier {
+	if "" == e.Identifier {
+		return nil
+	}
+	return rest.StringIdentifier(e.Identifier)
+}
+
+func (e testEntity) WithID(id rest.Identifier) (rest.IdentifiableEntity, error) {
+	e.Identifier = id.String()
+	return e, nil
+}
+
+type testUnmarshaller struct {
+	entity testEntity
+}
+
+func (u *testUnmarshaller) UnmarshalJSON(b []byte) error {
+	return json.Unmarshal(b, &u.entity)
+}
+
+func (u *testUnmarshaller) Entity() rest.IdentifiableEntity {
+	return u.entity
+}
+
+func newTestUnmarshaller() rest.Unmarshaller {
+	return &testUnmarshaller{}
+}
+
+func TestDefaultControllerConcurrentUpdates(t *testing.T) {
+	dao := rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{})
+	ctrl := rest.NewController("things", dao, nil, newTestUnmarshaller)
+
+	const nbRequests = 100
+	var wg sync.WaitGroup
+	for i := 0; i < nbRequests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := strconv.Itoa(i)
+			request := httptest.NewRequest("PUT", "http://localhost/things/"+id, strings.NewReader(`{"name":"name-`+id+`"}`))
+			result := httptest.NewRecorder()
+			ctrl.Update(result, request, httprouter.Params{{Key: "id", Value: id}})
+			if http.StatusOK != result.Code {
+				t.Errorf("Update %s returned %d: %s", id, result.Code, result.Body.String())
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < nbRequests; i++ {
+		id := strconv.Itoa(i)
+		entity, err := dao.Get(rest.StringIdentifier(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := "name-" + id; expected != entity.(testEntity).Name {
+			t.Errorf("Entity %s was persisted with name %s instead of %s", id, entity.(testEntity).Name, expected)
+		}
+	}
+}
+
+func TestDefaultControllerCreate(t *testing.T) {
+	dao := rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{})
+	ctrl := rest.NewController("things", dao, nil, newTestUnmarshaller)
+
+	result := httptest.NewRecorder()
+	ctrl.Create(result, httptest.NewRequest("POST", "http://localhost/things", strings.NewReader(`{"name":"created"}`)), nil)
+	if http.StatusCreated != result.Code {
+		t.Fatalf("Create returned %d instead of %d: %s", result.Code, http.StatusCreated, result.Body.String())
+	}
+	var created testEntity
+	if err := json.Unmarshal(result.Body.Bytes(), &created); nil != err {
+		t.Fatal(err)
+	}
+	if "" == created.Identifier || "created" != created.Name {
+		t.Errorf("Created entity doesn't carry its generated ID and its name: %+v", created)
+	}
+	if expected := "http://localhost/things/" + created.Identifier; expected != result.Header().Get("Location") {
+		t.Errorf("Location (%s) doesn't meet the expected result (%s)", result.Header().Get("Location"), expected)
+	}
+	if _, err := dao.Get(rest.StringIdentifier(created.Identifier)); nil != err {
+		t.Errorf("Created entity wasn't stored: %+v", err)
+	}
+}
+
+// rawIDEntity exposes its ID even when empty.
+type rawIDEntity struct {
+	Identifier string `json:"id"`
+}
+
+func (e rawIDEntity) ID() rest.Identifier {
+	return rest.StringIdentifier(e.Identifier)
+}
+
+func (e rawIDEntity) WithID(id rest.Identifier) (rest.IdentifiableEntity, error) {
+	e.Identifier = id.String()
+	return e, nil
+}
+
+type rawIDUnmarshaller struct {
+	entity rawIDEntity
+}
+
+func (u *rawIDUnmarshaller) UnmarshalJSON(b []byte) error {
+	return json.Unmarshal(b, &u.entity)
+}
+
+func (u *rawIDUnmarshaller) Entity() rest.IdentifiableEntity {
+	return u.entity
+}
+
+func TestDefaultControllerCreateWithID(t *testing.T) {
+	testcases := []struct {
+		name            string
+		body            string
+		newUnmarshaller rest.UnmarshallerFactory
+	}{
+		{"ID", `{"id":"1","name":"created"}`, newTestUnmarshaller},
+	}
+	for _, testdata := range testcases {
+		t.Run(testdata.name, func(t *testing.T) {
+			dao := rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{})
+			ctrl := rest.NewController("things", dao, nil, testdata.newUnmarshaller)
+			result := httptest.NewRecorder()
+			ctrl.Create(result, httptest.NewRequest("POST", "http://localhost/things", strings.NewReader(testdata.body)), nil)
+			if http.StatusBadRequest != result.Code {
+				t.Errorf("Create returned %d instead of %d: %s", result.Code, http.StatusBadRequest, result.Body.String())
+			}
+			if nb, _ := dao.TotalNumberOfEntities(); 0 != nb {
+				t.Errorf("Rejected entity was stored (%d entities)", nb)
+			}
+		})
+	}
+}
+
+func TestDefaultControllerCreateWithEmptyID(t *testing.T) {
+	dao := rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{})
+	ctrl := rest.NewController("things", dao, nil, func() rest.Unmarshaller { return &rawIDUnmarshaller{} })
+	result := httptest.NewRecorder()
+	ctrl.Create(result, httptest.NewRequest("POST", "http://localhost/things", strings.NewReader(`{"id":""}`)), nil)
+	if http.StatusCreated != result.Code {
+		t.Fatalf("Create returned %d instead of %d: %s", result.Code, http.StatusCreated, result.Body.String())
+	}
+	ids, err := dao.GetAllIDs(rest.Pagination{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 1 != len(ids) || "" == ids[0].String() {
+		t.Errorf("Stored IDs (%+v) don't meet the expected result (one generated ID)", ids)
+	}
+	if location := result.Header().Get("Location"); !strings.HasSuffix(location, "/"+ids[0].String()) {
+		t.Errorf("Location (%s) doesn't meet the expected result (%s)", location, ids[0])
+	}
+}
+
+func TestDefaultControllerUpdate(t *testing.T) {
+	dao := rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{})
+	if _, err := dao.Set(testEntity{Identifier: "existing", Name: "name"}); nil != err {
+		t.Fatal(err)
+	}
+	ctrl := rest.NewController("things", dao, nil, newTestUnmarshaller)
+	testcases := []struct {
+		name     string
+		id       string
+		body     string
+		status   int
+		expected testEntity
+	}{
+		{"Replace", "existing", `{"name":"replaced"}`, http.StatusOK, testEntity{Identifier: "existing", Name: "replaced"}},
+		{"Replace omitted fields", "existing", `{}`, http.StatusOK, testEntity{Identifier: "existing"}},
+		{"Create by ID", "new", `{"id":"new","name":"put"}`, http.StatusOK, testEntity{Identifier: "new", Name: "put"}},
+		{"Mismatching ID", "existing", `{"id":"other","name":"other"}`, http.StatusBadRequest, testEntity{Identifier: "existing"}},
+	}
+	for _, testdata := range testcases {
+		t.Run(testdata.name, func(t *testing.T) {
+			request := httptest.NewRequest("PUT", "http://localhost/things/"+testdata.id, strings.NewReader(testdata.body))
+			result := httptest.NewRecorder()
+			ctrl.Update(result, request, httprouter.Params{{Key: "id", Value: testdata.id}})
+			if testdata.status != result.Code {
+				t.Errorf("Update returned %d instead of %d: %s", result.Code, testdata.status, result.Body.String())
+			}
+			stored, err := dao.Get(rest.StringIdentifier(testdata.id))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if testdata.expected != stored {
+				t.Errorf("Stored entity (%+v) doesn't meet the expected result (%+v)", stored, testdata.expected)
+			}
+		})
+	}
+	if _, err := dao.Get(rest.StringIdentifier("other")); !rest.IsNotFound(err) {
+		t.Errorf("Entity stored under the mismatching body ID: %+v", err)
+	}
+}
+
+func TestDefaultControllerUnknownID(t *testing.T) {
+	ctrl := rest.NewController("things", rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{}), nil, newTestUnmarshaller)
+	testcases := []struct {
+		method  string
+		handler httprouter.Handle
+	}{
+		{"GET", ctrl.Get},
+		{"DELETE", ctrl.Delete},
+	}
+	for _, testdata := range testcases {
+		t.Run(testdata.method, func(t *testing.T) {
+			result := httptest.NewRecorder()
+			testdata.handler(result, httptest.NewRequest(testdata.method, "http://localhost/things/unknown", nil), httprouter.Params{{Key: "id", Value: "unknown"}})
+			if http.StatusNotFound != result.Code {
+				t.Errorf("%s returned %d instead of %d: %s", testdata.method, result.Code, http.StatusNotFound, result.Body.String())
+			}
+			if rest.ProblemMediaType != result.Header().Get("Content-Type") {
+				t.Errorf("Content-Type (%s) doesn't meet the expected result (%s)", result.Header().Get("Content-Type"), rest.ProblemMediaType)
+			}
+		})
+	}
+}
+
+// contextRecordingDAO records the error of the contexts it receives and fails with it, like a database driver would.
+type contextRecordingDAO struct {
+	*rest.MemoryDAO
+	lock     sync.Mutex
+	received []error
+}
+
+func (d *contextRecordingDAO) record(ctx context.Context) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.received = append(d.received, ctx.Err())
+	return ctx.Err()
+}
+
+func (d *contextRecordingDAO) GetAllEntitiesContext(ctx context.Context, p rest.Pagination) ([]rest.Entity, error) {
+	if err := d.record(ctx); nil != err {
+		return nil, err
+	}
+	return d.GetAllEntities(p)
+}
+
+func (d *contextRecordingDAO) GetAllIDsContext(ctx context.Context, p rest.Pagination) ([]rest.Identifier, error) {
+	if err := d.record(ctx); nil != err {
+		return nil, err
+	}
+	return d.GetAllIDs(p)
+}
+
+func (d *contextRecordingDAO) TotalNumberOfEntitiesContext(ctx context.Context) (int64, error) {
+	if err := d.record(ctx); nil != err {
+		return 0, err
+	}
+	return d.TotalNumberOfEntities()
+}
+
+func (d *contextRecordingDAO) GetContext(ctx context.Context, id rest.Identifier) (rest.Entity, error) {
+	if err := d.record(ctx); nil != err {
+		return nil, err
+	}
+	return d.Get(id)
+}
+
+func (d *contextRecordingDAO) SetContext(ctx context.Context, entity rest.IdentifiableEntity) (rest.Identifier, error) {
+	if err := d.record(ctx); nil != err {
+		return nil, err
+	}
+	return d.Set(entity)
+}
+
+func (d *contextRecordingDAO) DeleteContext(ctx context.Context, id rest.Identifier) error {
+	if err := d.record(ctx); nil != err {
+		return err
+	}
+	return d.Delete(id)
+}
+
+func TestDefaultControllerRequestContext(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	contexts := []struct {
+		name     string
+		ctx      context.Context
+		expected error
+		status   int
+	}{
+		{"Cancelled", cancelled, context.Canceled, rest.StatusClientClosedRequest},
+		{"Deadline exceeded", expired, context.DeadlineExceeded, http.StatusServiceUnavailable},
+	}
+	for _, ctxdata := range contexts {
+		t.Run(ctxdata.name, func(t *testing.T) {
+			dao := &contextRecordingDAO{MemoryDAO: rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{})}
+			if _, err := dao.Set(testEntity{Identifier: "1", Name: "name"}); nil != err {
+				t.Fatal(err)
+			}
+			ctrl := rest.NewController("things", dao, nil, newTestUnmarshaller)
+			params := httprouter.Params{{Key: "id", Value: "1"}}
+			testcases := []struct {
+				method  string
+				body    string
+				handler httprouter.Handle
+			}{
+				{"GET", "", ctrl.Get},
+				{"GET", "", ctrl.GetAll},
+				{"POST", `{"name":"created"}`, ctrl.Create},
+				{"PUT", `{"name":"updated"}`, ctrl.Update},
+				{"DELETE", "", ctrl.Delete},
+			}
+			for _, testdata := range testcases {
+				dao.received = nil
+				request := httptest.NewRequest(testdata.method, "http://localhost/things/1", strings.NewReader(testdata.body)).WithContext(ctxdata.ctx)
+				result := httptest.NewRecorder()
+				testdata.handler(result, request, params)
+				if ctxdata.status != result.Code {
+					t.Errorf("%s returned %d instead of %d: %s", testdata.method, result.Code, ctxdata.status, result.Body.String())
+				}
+				if 0 == len(dao.received) || ctxdata.expected != dao.received[0] {
+					t.Errorf("%s didn't pass the request context to the DAO: %+v", testdata.method, dao.received)
+				}
+			}
+			if stored, err := dao.Get(rest.StringIdentifier("1")); nil != err || "name" != stored.(testEntity).Name {
+				t.Errorf("Entity modified through a cancelled request: %+v (%+v)", stored, err)
+			}
+		})
+	}
+}
+
+func TestAsContextDAOCancelled(t *testing.T) {
+	dao := rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	adapted := rest.AsContextDAO(struct{ rest.DAO }{dao})
+	if _, err := adapted.SetContext(ctx, testEntity{Name: "name"}); context.Canceled != err {
+		t.Errorf("Expected %+v but got %+v", context.Canceled, err)
+	}
+	if _, err := adapted.GetAllEntitiesContext(ctx, rest.Pagination{}); context.Canceled != err {
+		t.Errorf("Expected %+v but got %+v", context.Canceled, err)
+	}
+	if nb, _ := dao.TotalNumberOfEntities(); 0 != nb {
+		t.Errorf("Adapted DAO was called with a cancelled context (%d entities)", nb)
+	}
+}
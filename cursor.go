@@ -0,0 +1,143 @@
+package rest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Keyset restricts a collection, ordered by primary key, to the entities after (or before, when Backward) Key.
+// An empty Key targets the start of the collection, or its end when Backward.
+type Keyset struct {
+	Key      string `json:"k,omitempty"`
+	Backward bool   `json:"b,omitempty"`
+}
+
+func (k Keyset) includes(id Identifier) bool {
+	if "" == k.Key {
+		return true
+	}
+	if k.Backward {
+		return compareKey(id, k.Key) < 0
+	}
+	return compareKey(id, k.Key) > 0
+}
+
+// compareKey orders id and key like databases order primary keys: numerically when the identifier type is numeric,
+// as strings otherwise.
+func compareKey(id Identifier, key string) int {
+	value := reflect.ValueOf(id)
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if other, err := strconv.ParseInt(key, 10, 64); nil == err {
+			return compareOrdered(value.Int() < other, value.Int() > other)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if other, err := strconv.ParseUint(key, 10, 64); nil == err {
+			return compareOrdered(value.Uint() < other, value.Uint() > other)
+		}
+	case reflect.Float32, reflect.Float64:
+		if other, err := strconv.ParseFloat(key, 64); nil == err {
+			return compareOrdered(value.Float() < other, value.Float() > other)
+		}
+	}
+	return strings.Compare(id.String(), key)
+}
+
+func compareOrdered(lower bool, greater bool) int {
+	if lower {
+		return -1
+	}
+	if greater {
+		return 1
+	}
+	return 0
+}
+
+// CursorCodec turns keysets into opaque cursors signed with HMAC-SHA256, so clients cannot forge them.
+type CursorCodec struct {
+	secret []byte
+}
+
+func NewCursorCodec(secret []byte) *CursorCodec {
+	return &CursorCodec{secret: secret}
+}
+
+func (c *CursorCodec) Encode(keyset Keyset) (string, error) {
+	payload, err := json.Marshal(keyset)
+	if err != nil {
+		return "", errors.Wrapf(err, "Encoding keyset '%+v'", keyset)
+	}
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(c.sign(payload)), nil
+}
+
+func (c *CursorCodec) Decode(cursor string) (Keyset, error) {
+	parts := strings.Split(cursor, ".")
+	if 2 != len(parts) {
+		return Keyset{}, NewError(KindValidation, nil, "Malformed cursor '%s'", cursor)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return Keyset{}, NewError(KindValidation, err, "Malformed cursor '%s'", cursor)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return Keyset{}, NewError(KindValidation, err, "Malformed cursor '%s'", cursor)
+	}
+	if !hmac.Equal(signature, c.sign(payload)) {
+		return Keyset{}, NewError(KindValidation, nil, "Invalid cursor signature '%s'", cursor)
+	}
+	var keyset Keyset
+	if err = json.Unmarshal(payload, &keyset); nil != err {
+		return Keyset{}, NewError(KindValidation, err, "Malformed cursor '%s'", cursor)
+	}
+	return keyset, nil
+}
+
+func (c *CursorCodec) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// loadKeyset reads the `after` or `before` cursor from the query parameters.
+func loadKeyset(params url.Values, codec *CursorCodec) (Keyset, error) {
+	after := params.Get("after")
+	before := params.Get("before")
+	if "" != after && "" != before {
+		return Keyset{}, NewError(KindValidation, nil, "Cannot use both 'after' and 'before' cursors")
+	}
+	if "" != params.Get("offset") {
+		return Keyset{}, NewError(KindValidation, nil, "Offset cannot be used with cursors")
+	}
+	cursor := after
+	if "" != before {
+		cursor = before
+	}
+	if "" == cursor {
+		return Keyset{}, nil
+	}
+	keyset, err := codec.Decode(cursor)
+	if err != nil {
+		return Keyset{}, err
+	}
+	if keyset.Backward != ("" != before) {
+		return Keyset{}, NewError(KindValidation, nil, "Cursor '%s' used in the wrong direction", cursor)
+	}
+	return keyset, nil
+}
+
+// CursorPage describes the boundaries of a page loaded with a Keyset.
+type CursorPage struct {
+	FirstKey    string
+	LastKey     string
+	HasPrevious bool
+	HasNext     bool
+}
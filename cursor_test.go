@@ -0,0 +1,97 @@
+package rest_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/normegil/rest"
+)
+
+type cursorResponse struct {
+	First    string      `json:"first"`
+	Last     string      `json:"last"`
+	Previous string      `json:"previous"`
+	Next     string      `json:"next"`
+	Items    []sqlEntity `json:"items"`
+}
+
+func getCursorPage(t *testing.T, ctrl *rest.DefaultController, target string) (int, cursorResponse) {
+	t.Helper()
+	result := httptest.NewRecorder()
+	ctrl.GetAll(result, httptest.NewRequest("GET", target, nil), httprouter.Params{})
+	var response cursorResponse
+	if http.StatusOK == result.Code {
+		if err := json.Unmarshal(result.Body.Bytes(), &response); nil != err {
+			t.Fatal(err)
+		}
+	}
+	return result.Code, response
+}
+
+func TestDefaultControllerCursorPagination(t *testing.T) {
+	mapper, err := rest.NewStructMapper(sqlEntity{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	daos := map[string]rest.DAO{
+		"Memory":   rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{}),
+		"Database": newSQLiteDAO(t, mapper, rest.NewMapperQueries(rest.SQLite, "things", mapper)),
+	}
+	for daoName, dao := range daos {
+		t.Run(daoName, func(t *testing.T) {
+			for _, id := range []string{"d", "b", "e", "a", "c"} {
+				if _, err := dao.Set(sqlEntity{Identifier: id, Name: "entity-" + id}); nil != err {
+					t.Fatal(err)
+				}
+			}
+			ctrl := rest.NewController("things", dao, nil, nil)
+			ctrl.Cursors = rest.NewCursorCodec([]byte("secret"))
+
+			forward := make([]string, 0)
+			target := "http://localhost/things?expand=true&limit=2"
+			for "" != target {
+				code, response := getCursorPage(t, ctrl, target)
+				if http.StatusOK != code {
+					t.Fatalf("GetAll %s returned %d", target, code)
+				}
+				for _, item := range response.Items {
+					forward = append(forward, item.Identifier)
+				}
+				target = response.Next
+			}
+			if expected := []string{"a", "b", "c", "d", "e"}; !reflect.DeepEqual(expected, forward) {
+				t.Errorf("Forward walk (%v) doesn't meet the expected result (%v)", forward, expected)
+			}
+
+			_, first := getCursorPage(t, ctrl, "http://localhost/things?expand=true&limit=2")
+			backward := make([]string, 0)
+			target = first.Last
+			for "" != target {
+				code, response := getCursorPage(t, ctrl, target)
+				if http.StatusOK != code {
+					t.Fatalf("GetAll %s returned %d", target, code)
+				}
+				for i := len(response.Items) - 1; i >= 0; i-- {
+					backward = append(backward, response.Items[i].Identifier)
+				}
+				target = response.Previous
+			}
+			if expected := []string{"e", "d", "c", "b", "a"}; !reflect.DeepEqual(expected, backward) {
+				t.Errorf("Backward walk (%v) doesn't meet the expected result (%v)", backward, expected)
+			}
+
+			tampered := strings.Replace(first.Next, "after=", "after=x", 1)
+			if code, _ := getCursorPage(t, ctrl, tampered); http.StatusBadRequest != code {
+				t.Errorf("Tampered cursor returned %d instead of %d", code, http.StatusBadRequest)
+			}
+			if code, _ := getCursorPage(t, ctrl, "http://localhost/things?offset=2"); http.StatusBadRequest != code {
+				t.Errorf("Offset in cursor mode returned %d instead of %d", code, http.StatusBadRequest)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+type ErrorKind string
+
+const (
+	KindValidation           = ErrorKind("validation")
+	KindNotFound             = ErrorKind("not-found")
+	KindMethodNotAllowed     = ErrorKind("method-not-allowed")
+	KindConflict             = ErrorKind("conflict")
+	KindUnauthorized         = ErrorKind("unauthorized")
+	KindUnsupportedMediaType = ErrorKind("unsupported-media-type")
+	KindNotAcceptable        = ErrorKind("not-acceptable")
+	KindPreconditionFailed   = ErrorKind("precondition-failed")
+	KindPreconditionRequired = ErrorKind("precondition-required")
+	KindCanceled             = ErrorKind("canceled")
+	KindTimeout              = ErrorKind("timeout")
+	KindInternal             = ErrorKind("internal")
+)
+
+// StatusClientClosedRequest is the non standard status of requests canceled by their client.
+const StatusClientClosedRequest = 499
+
+func (k ErrorKind) Status() int {
+	switch k {
+	case KindValidation:
+		return http.StatusBadRequest
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindMethodNotAllowed:
+		return http.StatusMethodNotAllowed
+	case KindConflict:
+		return http.StatusConflict
+	case KindUnauthorized:
+		return http.StatusUnauthorized
+	case KindUnsupportedMediaType:
+		return http.StatusUnsupportedMediaType
+	case KindNotAcceptable:
+		return http.StatusNotAcceptable
+	case KindPreconditionFailed:
+		return http.StatusPreconditionFailed
+	case KindPreconditionRequired:
+		return http.StatusPreconditionRequired
+	case KindCanceled:
+		return StatusClientClosedRequest
+	case KindTimeout:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Error carries a kind and a client safe detail. The wrapped error is only used for logging.
+type Error struct {
+	Kind   ErrorKind
+	Detail string
+	Err    error
+}
+
+func NewError(kind ErrorKind, err error, format string, args ...interface{}) *Error {
+	return &Error{
+		Kind:   kind,
+		Detail: fmt.Sprintf(format, args...),
+		Err:    err,
+	}
+}
+
+func (e *Error) Error() string {
+	if nil == e.Err {
+		return e.Detail
+	}
+	return e.Detail + ": " + e.Err.Error()
+}
+
+func (e *Error) ErrorKind() ErrorKind {
+	return e.Kind
+}
+
+func (e *Error) ErrorDetail() string {
+	return e.Detail
+}
+
+type kindedError interface {
+	ErrorKind() ErrorKind
+	ErrorDetail() string
+}
+
+type causer interface {
+	Cause() error
+}
+
+// findKindedError follows both Cause and Unwrap chains. Context errors are classified as they are found.
+func findKindedError(err error) kindedError {
+	for nil != err {
+		if kinded, ok := err.(kindedError); ok {
+			return kinded
+		}
+		switch err {
+		case context.Canceled:
+			return NewError(KindCanceled, err, "Request canceled")
+		case context.DeadlineExceeded:
+			return NewError(KindTimeout, err, "Request deadline exceeded")
+		}
+		if c, ok := err.(causer); ok {
+			err = c.Cause()
+		} else {
+			err = errors.Unwrap(err)
+		}
+	}
+	return nil
+}
+
+// ErrorKindOf walks the wrap chain of err and returns the first kind found, or KindInternal.
+func ErrorKindOf(err error) ErrorKind {
+	if kinded := findKindedError(err); nil != kinded {
+		return kinded.ErrorKind()
+	}
+	return KindInternal
+}
+
+const ProblemMediaType = "application/problem+json"
+
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
+
+func NewProblem(err error) Problem {
+	kind := KindInternal
+	var detail string
+	if kinded := findKindedError(err); nil != kinded {
+		kind = kinded.ErrorKind()
+		detail = kinded.ErrorDetail()
+	}
+	status := kind.Status()
+	if KindInternal == kind {
+		detail = ""
+	}
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+type ProblemErrorHandler struct{}
+
+// Handle doesn't write any body for canceled requests, as nobody is left to read it.
+func (h ProblemErrorHandler) Handle(w http.ResponseWriter, err error) error {
+	problem := NewProblem(err)
+	if StatusClientClosedRequest == problem.Status {
+		w.WriteHeader(problem.Status)
+		return nil
+	}
+	return WriteProblem(w, problem)
+}
+
+func WriteProblem(w http.ResponseWriter, problem Problem) error {
+	body, err := json.Marshal(problem)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", ProblemMediaType)
+	w.WriteHeader(problem.Status)
+	_, err = w.Write(body)
+	return err
+}
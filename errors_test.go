@@ -0,0 +1,86 @@
+package rest_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/normegil/rest"
+	pkgErrors "github.com/pkg/errors"
+)
+
+func TestProblemErrorHandler(t *testing.T) {
+	testcases := []struct {
+		name   string
+		err    error
+		status int
+		detail string
+	}{
+		{"Unknown error", errors.New("sql: connection refused"), http.StatusInternalServerError, ""},
+		{"Validation", rest.NewError(rest.KindValidation, nil, "Invalid offset"), http.StatusBadRequest, "Invalid offset"},
+		{"Wrapped validation", pkgErrors.Wrapf(rest.NewError(rest.KindValidation, errors.New("strconv"), "Invalid limit"), "Loading"), http.StatusBadRequest, "Invalid limit"},
+		{"Not found", pkgErrors.Wrap(rest.NotFoundError{ID: rest.StringIdentifier("1")}, "Get"), http.StatusNotFound, "Entity identified by '1' not found"},
+		{"Conflict", rest.NewError(rest.KindConflict, errors.New("UNIQUE constraint failed"), "Operation violates a constraint"), http.StatusConflict, "Operation violates a constraint"},
+		{"Unauthorized", rest.NewError(rest.KindUnauthorized, nil, "Missing token"), http.StatusUnauthorized, "Missing token"},
+		{"Unsupported media type", rest.UnsupportedPatchError{MediaType: "text/plain"}, http.StatusUnsupportedMediaType, "Unsupported patch media type 'text/plain'"},
+		{"Internal", rest.NewError(rest.KindInternal, errors.New("secret"), "Should not leak"), http.StatusInternalServerError, ""},
+		{"Deadline exceeded", pkgErrors.Wrap(context.DeadlineExceeded, "Get"), http.StatusServiceUnavailable, "Request deadline exceeded"},
+		{"Unwrapped deadline exceeded", fmt.Errorf("query: %w", context.DeadlineExceeded), http.StatusServiceUnavailable, "Request deadline exceeded"},
+	}
+	for _, testdata := range testcases {
+		t.Run(testdata.name, func(t *testing.T) {
+			result := httptest.NewRecorder()
+			if err := (rest.ProblemErrorHandler{}).Handle(result, testdata.err); nil != err {
+				t.Fatal(err)
+			}
+			if testdata.status != result.Code {
+				t.Errorf("Status (%d) doesn't meet the expected result (%d)", result.Code, testdata.status)
+			}
+			if rest.ProblemMediaType != result.Header().Get("Content-Type") {
+				t.Errorf("Content-Type (%s) doesn't meet the expected result (%s)", result.Header().Get("Content-Type"), rest.ProblemMediaType)
+			}
+			var problem rest.Problem
+			if err := json.Unmarshal(result.Body.Bytes(), &problem); nil != err {
+				t.Fatal(err)
+			}
+			if testdata.status != problem.Status {
+				t.Errorf("Problem status (%d) doesn't meet the expected result (%d)", problem.Status, testdata.status)
+			}
+			if testdata.detail != problem.Detail {
+				t.Errorf("Problem detail (%s) doesn't meet the expected result (%s)", problem.Detail, testdata.detail)
+			}
+		})
+	}
+}
+
+func TestProblemErrorHandlerCanceled(t *testing.T) {
+	testcases := []struct {
+		name string
+		err  error
+	}{
+		{"Canceled", context.Canceled},
+		{"Wrapped", pkgErrors.Wrap(context.Canceled, "Get")},
+		{"Unwrapped", fmt.Errorf("query: %w", pkgErrors.Wrap(context.Canceled, "Get"))},
+	}
+	for _, testdata := range testcases {
+		t.Run(testdata.name, func(t *testing.T) {
+			if rest.KindCanceled != rest.ErrorKindOf(testdata.err) {
+				t.Errorf("Kind (%s) doesn't meet the expected result (%s)", rest.ErrorKindOf(testdata.err), rest.KindCanceled)
+			}
+			result := httptest.NewRecorder()
+			if err := (rest.ProblemErrorHandler{}).Handle(result, testdata.err); nil != err {
+				t.Fatal(err)
+			}
+			if rest.StatusClientClosedRequest != result.Code {
+				t.Errorf("Status (%d) doesn't meet the expected result (%d)", result.Code, rest.StatusClientClosedRequest)
+			}
+			if 0 != result.Body.Len() {
+				t.Errorf("Body (%s) doesn't meet the expected result (empty)", result.Body.String())
+			}
+		})
+	}
+}
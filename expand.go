@@ -0,0 +1,300 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Relation links the identifiers found at a JSON property path of an entity to the resource registered under BasePath.
+// The property may hold a single identifier or an array of identifiers, and may go through nested objects (e.g. "items.product").
+type Relation struct {
+	Property string
+	BasePath string
+}
+
+// RelatedEntity is implemented by entities whose related resources can be embedded with `expand=relation`.
+type RelatedEntity interface {
+	Relations() []Relation
+}
+
+// Registry gives access to the DAO of every registered controller, to load related resources.
+type Registry struct {
+	lock sync.RWMutex
+	daos map[string]DAO
+}
+
+func NewRegistry() *Registry {
+	return &Registry{daos: make(map[string]DAO)}
+}
+
+func (r *Registry) Add(basePath string, dao DAO) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.daos[basePath] = dao
+}
+
+func (r *Registry) DAO(basePath string) (DAO, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	dao, found := r.daos[basePath]
+	return dao, found
+}
+
+func (r *Registry) all() []DAO {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	daos := make([]DAO, 0, len(r.daos))
+	for _, dao := range r.daos {
+		daos = append(daos, dao)
+	}
+	return daos
+}
+
+// RegistryBinder is implemented by controllers needing the Registry of the Router they are registered on.
+type RegistryBinder interface {
+	BindRegistry(*Registry)
+}
+
+// BatchDAO is implemented by DAOs able to load several entities in one call. Unknown identifiers are absent from the result.
+type BatchDAO interface {
+	GetBatch(context.Context, []Identifier) (map[string]Entity, error)
+}
+
+func getBatch(ctx context.Context, dao DAO, ids []Identifier) (map[string]Entity, error) {
+	if batchDAO, ok := dao.(BatchDAO); ok {
+		return batchDAO.GetBatch(ctx, ids)
+	}
+	entities := make(map[string]Entity)
+	for _, id := range ids {
+		entity, err := AsContextDAO(dao).GetContext(ctx, id)
+		if IsNotFound(err) || (nil == err && nil == entity) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "Get related entity '%s'", id)
+		}
+		entities[id.String()] = entity
+	}
+	return entities, nil
+}
+
+// Expansion is the tree of relations requested through `expand=owner,items.product`.
+type Expansion map[string]Expansion
+
+// ParseExpand reads the expand parameter. Boolean values only tell whether collection items are embedded.
+func ParseExpand(params url.Values) (bool, Expansion, error) {
+	expandStr := params.Get("expand")
+	if "" == expandStr {
+		return false, nil, nil
+	}
+	if expand, err := strconv.ParseBool(expandStr); nil == err {
+		return expand, nil, nil
+	}
+	paths, err := parsePaths(expandStr)
+	if err != nil {
+		return false, nil, err
+	}
+	return true, toExpansion(paths), nil
+}
+
+func toExpansion(paths Fieldset) Expansion {
+	expansion := make(Expansion)
+	for property, sub := range paths {
+		expansion[property] = toExpansion(sub)
+	}
+	return expansion
+}
+
+type expander struct {
+	registry *Registry
+}
+
+// expand returns the JSON representations of entities with the requested relations embedded.
+// Related entities are loaded with one batch per relation and nesting level.
+func (e expander) expand(ctx context.Context, entities []Entity, expansion Expansion) ([]interface{}, error) {
+	representations := make([]interface{}, 0, len(entities))
+	for _, entity := range entities {
+		representation, err := numberPreservingRepresentation(entity)
+		if err != nil {
+			return nil, err
+		}
+		representations = append(representations, representation)
+	}
+	if 0 == len(expansion) || 0 == len(entities) {
+		return representations, nil
+	}
+	related, ok := entities[0].(RelatedEntity)
+	if !ok {
+		return nil, NewError(KindValidation, nil, "Entity has no relation to expand")
+	}
+	if err := e.expandPaths(ctx, related.Relations(), representations, expansion, ""); nil != err {
+		return nil, err
+	}
+	return representations, nil
+}
+
+func (e expander) expandPaths(ctx context.Context, relations []Relation, representations []interface{}, expansion Expansion, prefix string) error {
+	for property, sub := range expansion {
+		path := prefix + property
+		relation, found := findRelation(relations, path)
+		if !found {
+			if 0 == len(sub) {
+				return NewError(KindValidation, nil, "Unknown relation '%s'", path)
+			}
+			if err := e.expandPaths(ctx, relations, representations, sub, path+"."); nil != err {
+				return err
+			}
+			continue
+		}
+		if err := e.expandRelation(ctx, relation, representations, sub); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+func findRelation(relations []Relation, path string) (Relation, bool) {
+	for _, relation := range relations {
+		if relation.Property == path {
+			return relation, true
+		}
+	}
+	return Relation{}, false
+}
+
+func (e expander) expandRelation(ctx context.Context, relation Relation, representations []interface{}, sub Expansion) error {
+	if nil == e.registry {
+		return fmt.Errorf("No registry to expand relation '%s'", relation.Property)
+	}
+	dao, found := e.registry.DAO(relation.BasePath)
+	if !found {
+		return fmt.Errorf("No resource registered under '%s' for relation '%s'", relation.BasePath, relation.Property)
+	}
+
+	segments := strings.Split(relation.Property, ".")
+	ids := make([]Identifier, 0)
+	seen := make(map[string]bool)
+	for _, representation := range representations {
+		visitProperty(representation, segments, func(value interface{}) interface{} {
+			for _, id := range relatedIDs(value) {
+				if !seen[id] {
+					seen[id] = true
+					ids = append(ids, StringIdentifier(id))
+				}
+			}
+			return value
+		})
+	}
+	if 0 == len(ids) {
+		return nil
+	}
+
+	relatedEntities, err := getBatch(ctx, dao, ids)
+	if err != nil {
+		return errors.Wrapf(err, "Loading relation '%s'", relation.Property)
+	}
+	loaded := make([]Entity, 0, len(relatedEntities))
+	keys := make([]string, 0, len(relatedEntities))
+	for key, entity := range relatedEntities {
+		loaded = append(loaded, entity)
+		keys = append(keys, key)
+	}
+	expanded, err := e.expand(ctx, loaded, sub)
+	if err != nil {
+		return errors.Wrapf(err, "Expanding relation '%s'", relation.Property)
+	}
+	byID := make(map[string]interface{})
+	for i, key := range keys {
+		byID[key] = expanded[i]
+	}
+
+	// Identifiers of missing entities are left in place, so that dangling references stay visible
+	replace := func(value interface{}) interface{} {
+		if related, found := byID[idString(value)]; found {
+			return related
+		}
+		return value
+	}
+	for _, representation := range representations {
+		visitProperty(representation, segments, func(value interface{}) interface{} {
+			if values, isArray := value.([]interface{}); isArray {
+				replaced := make([]interface{}, 0, len(values))
+				for _, element := range values {
+					replaced = append(replaced, replace(element))
+				}
+				return replaced
+			}
+			return replace(value)
+		})
+	}
+	return nil
+}
+
+// numberPreservingRepresentation decodes numbers as json.Number, so that numeric identifiers above 2^53 stay exact.
+func numberPreservingRepresentation(entity Entity) (interface{}, error) {
+	jsonEntity, err := json.Marshal(entity)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Encoding entity into json '%+v'", entity)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(jsonEntity))
+	decoder.UseNumber()
+	var representation interface{}
+	if err = decoder.Decode(&representation); nil != err {
+		return nil, errors.Wrapf(err, "Decoding json representation %s", string(jsonEntity))
+	}
+	return representation, nil
+}
+
+// visitProperty replaces the values found at the property path, going through nested objects and arrays.
+func visitProperty(representation interface{}, segments []string, replace func(interface{}) interface{}) {
+	switch typed := representation.(type) {
+	case []interface{}:
+		for _, element := range typed {
+			visitProperty(element, segments, replace)
+		}
+	case map[string]interface{}:
+		value, found := typed[segments[0]]
+		if !found || nil == value {
+			return
+		}
+		if 1 == len(segments) {
+			typed[segments[0]] = replace(value)
+			return
+		}
+		visitProperty(value, segments[1:], replace)
+	}
+}
+
+func relatedIDs(value interface{}) []string {
+	if values, isArray := value.([]interface{}); isArray {
+		ids := make([]string, 0, len(values))
+		for _, element := range values {
+			if id := idString(element); "" != id {
+				ids = append(ids, id)
+			}
+		}
+		return ids
+	}
+	if id := idString(value); "" != id {
+		return []string{id}
+	}
+	return nil
+}
+
+func idString(value interface{}) string {
+	switch typed := value.(type) {
+	case string:
+		return typed
+	case json.Number:
+		return typed.String()
+	}
+	return ""
+}
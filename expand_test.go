@@ -0,0 +1,246 @@
+package rest_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/normegil/rest"
+)
+
+type orderItem struct {
+	Product  string `json:"product"`
+	Quantity int    `json:"quantity"`
+}
+
+type orderEntity struct {
+	Identifier string      `json:"id,omitempty"`
+	Owner      string      `json:"owner"`
+	Items      []orderItem `json:"items"`
+}
+
+func (e orderEntity) ID() rest.Identifier {
+	if "" == e.Identifier {
+		return nil
+	}
+	return rest.StringIdentifier(e.Identifier)
+}
+
+func (e orderEntity) WithID(id rest.Identifier) (rest.IdentifiableEntity, error) {
+	e.Identifier = id.String()
+	return e, nil
+}
+
+func (e orderEntity) Relations() []rest.Relation {
+	return []rest.Relation{
+		{Property: "owner", BasePath: "users"},
+		{Property: "items.product", BasePath: "products"},
+	}
+}
+
+type orderUnmarshaller struct {
+	entity orderEntity
+}
+
+func (u *orderUnmarshaller) UnmarshalJSON(b []byte) error {
+	return json.Unmarshal(b, &u.entity)
+}
+
+func (u *orderUnmarshaller) Entity() rest.IdentifiableEntity {
+	return u.entity
+}
+
+// batchCountingDAO counts the batches loaded to expand relations.
+type batchCountingDAO struct {
+	*rest.MemoryDAO
+	batches int
+}
+
+func (d *batchCountingDAO) GetBatch(ctx context.Context, ids []rest.Identifier) (map[string]rest.Entity, error) {
+	d.batches++
+	return d.MemoryDAO.GetBatch(ctx, ids)
+}
+
+func TestDefaultControllerExpandRelations(t *testing.T) {
+	users := &batchCountingDAO{MemoryDAO: rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{})}
+	products := &batchCountingDAO{MemoryDAO: rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{})}
+	orders := rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{})
+	mustSet := func(dao rest.DAO, entity rest.IdentifiableEntity) {
+		if _, err := dao.Set(entity); nil != err {
+			t.Fatal(err)
+		}
+	}
+	mustSet(users, testEntity{Identifier: "alice", Name: "Alice"})
+	mustSet(products, testEntity{Identifier: "pen", Name: "Pen"})
+	mustSet(products, testEntity{Identifier: "ink", Name: "Ink"})
+	mustSet(orders, orderEntity{Identifier: "1", Owner: "alice", Items: []orderItem{{Product: "pen", Quantity: 1}, {Product: "ink", Quantity: 2}}})
+	mustSet(orders, orderEntity{Identifier: "2", Owner: "alice", Items: []orderItem{{Product: "pen", Quantity: 3}}})
+
+	router := rest.NewRouter()
+	ctrl := rest.NewController("orders", orders, nil, nil)
+	for _, controller := range []rest.Controller{
+		ctrl,
+		rest.NewCORSController(rest.NewController("users", users, nil, nil), "*"),
+		rest.NewController("products", products, nil, nil),
+	} {
+		if err := router.Register(controller); nil != err {
+			t.Fatal(err)
+		}
+	}
+
+	result := httptest.NewRecorder()
+	ctrl.Get(result, httptest.NewRequest("GET", "http://localhost/orders/1?expand=owner,items.product", nil), httprouter.Params{{Key: "id", Value: "1"}})
+	expected := `{"id":"1","items":[{"product":{"id":"pen","name":"Pen"},"quantity":1},{"product":{"id":"ink","name":"Ink"},"quantity":2}],"owner":{"id":"alice","name":"Alice"}}`
+	if expected != result.Body.String() {
+		t.Errorf("Body (%s) doesn't meet the expected result (%s)", result.Body.String(), expected)
+	}
+
+	users.batches, products.batches = 0, 0
+	result = httptest.NewRecorder()
+	ctrl.GetAll(result, httptest.NewRequest("GET", "http://localhost/orders?expand=owner,items.product", nil), nil)
+	if http.StatusOK != result.Code {
+		t.Fatalf("GetAll returned %d: %s", result.Code, result.Body.String())
+	}
+	var response struct {
+		Items []orderRepresentation `json:"items"`
+	}
+	if err := json.Unmarshal(result.Body.Bytes(), &response); nil != err {
+		t.Fatal(err)
+	}
+	if 2 != len(response.Items) || "Alice" != response.Items[1].Owner.Name || "Pen" != response.Items[1].Items[0].Product.Name {
+		t.Errorf("Collection wasn't expanded: %s", result.Body.String())
+	}
+	if 1 != users.batches || 1 != products.batches {
+		t.Errorf("Relations loaded in %d and %d batches instead of one each", users.batches, products.batches)
+	}
+
+	for _, expand := range []string{"unknown", "items.unknown"} {
+		result = httptest.NewRecorder()
+		ctrl.Get(result, httptest.NewRequest("GET", "http://localhost/orders/1?expand="+expand, nil), httprouter.Params{{Key: "id", Value: "1"}})
+		if http.StatusBadRequest != result.Code {
+			t.Errorf("Expanding '%s' returned %d instead of %d", expand, result.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+type orderRepresentation struct {
+	Owner testEntity `json:"owner"`
+	Items []struct {
+		Product testEntity `json:"product"`
+	} `json:"items"`
+}
+
+func TestDefaultControllerExpandedFields(t *testing.T) {
+	users := rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{})
+	products := rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{})
+	orders := rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{})
+	for dao, entity := range map[rest.DAO]rest.IdentifiableEntity{
+		users:    testEntity{Identifier: "alice", Name: "Alice"},
+		products: testEntity{Identifier: "pen", Name: "Pen"},
+		orders:   orderEntity{Identifier: "1", Owner: "alice", Items: []orderItem{{Product: "pen", Quantity: 1}}},
+	} {
+		if _, err := dao.Set(entity); nil != err {
+			t.Fatal(err)
+		}
+	}
+	router := rest.NewRouter()
+	ctrl := rest.NewController("orders", orders, nil, func() rest.Unmarshaller { return &orderUnmarshaller{} })
+	for _, controller := range []rest.Controller{ctrl, rest.NewController("users", users, nil, nil), rest.NewController("products", products, nil, nil)} {
+		if err := router.Register(controller); nil != err {
+			t.Fatal(err)
+		}
+	}
+
+	testcases := []struct {
+		query    string
+		status   int
+		expected string
+	}{
+		{"expand=owner&fields=owner.name", http.StatusOK, `{"owner":{"name":"Alice"}}`},
+		{"expand=items.product&fields=id,items.product.name", http.StatusOK, `{"id":"1","items":[{"product":{"name":"Pen"}}]}`},
+		{"fields=owner.name", http.StatusBadRequest, ""},
+		{"expand=owner&fields=items.unknown", http.StatusBadRequest, ""},
+	}
+	for _, testdata := range testcases {
+		t.Run(testdata.query, func(t *testing.T) {
+			result := httptest.NewRecorder()
+			ctrl.Get(result, httptest.NewRequest("GET", "http://localhost/orders/1?"+testdata.query, nil), httprouter.Params{{Key: "id", Value: "1"}})
+			if testdata.status != result.Code {
+				t.Fatalf("Status (%d) doesn't meet the expected result (%d): %s", result.Code, testdata.status, result.Body.String())
+			}
+			if "" != testdata.expected && testdata.expected != result.Body.String() {
+				t.Errorf("Body (%s) doesn't meet the expected result (%s)", result.Body.String(), testdata.expected)
+			}
+		})
+	}
+
+	result := httptest.NewRecorder()
+	ctrl.GetAll(result, httptest.NewRequest("GET", "http://localhost/orders?expand=owner&fields=owner.name", nil), nil)
+	if http.StatusOK != result.Code || !strings.Contains(result.Body.String(), `{"owner":{"name":"Alice"}}`) {
+		t.Errorf("GetAll returned %d: %s", result.Code, result.Body.String())
+	}
+}
+
+// ticketEntity refers to its assignee by a numeric identifier.
+type ticketEntity struct {
+	Identifier string `json:"id"`
+	Assignee   int64  `json:"assignee"`
+}
+
+func (e ticketEntity) ID() rest.Identifier {
+	return rest.StringIdentifier(e.Identifier)
+}
+
+func (e ticketEntity) WithID(id rest.Identifier) (rest.IdentifiableEntity, error) {
+	e.Identifier = id.String()
+	return e, nil
+}
+
+func (e ticketEntity) Relations() []rest.Relation {
+	return []rest.Relation{{Property: "assignee", BasePath: "users"}}
+}
+
+func TestDefaultControllerExpandIdentifiers(t *testing.T) {
+	users := rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{})
+	tickets := rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{})
+	for dao, entity := range map[rest.DAO]rest.IdentifiableEntity{
+		users:   testEntity{Identifier: "9007199254740993", Name: "Big"},
+		tickets: ticketEntity{Identifier: "1", Assignee: 9007199254740993},
+	} {
+		if _, err := dao.Set(entity); nil != err {
+			t.Fatal(err)
+		}
+	}
+	if _, err := tickets.Set(ticketEntity{Identifier: "2", Assignee: 42}); nil != err {
+		t.Fatal(err)
+	}
+	router := rest.NewRouter()
+	ctrl := rest.NewController("tickets", tickets, nil, nil)
+	for _, controller := range []rest.Controller{ctrl, rest.NewController("users", users, nil, nil)} {
+		if err := router.Register(controller); nil != err {
+			t.Fatal(err)
+		}
+	}
+
+	testcases := []struct {
+		name     string
+		id       string
+		expected string
+	}{
+		{"Large identifier", "1", `{"assignee":{"id":"9007199254740993","name":"Big"},"id":"1"}`},
+		{"Missing entity", "2", `{"assignee":42,"id":"2"}`},
+	}
+	for _, testdata := range testcases {
+		t.Run(testdata.name, func(t *testing.T) {
+			result := httptest.NewRecorder()
+			ctrl.Get(result, httptest.NewRequest("GET", "http://localhost/tickets/"+testdata.id+"?expand=assignee", nil), httprouter.Params{{Key: "id", Value: testdata.id}})
+			if testdata.expected != result.Body.String() {
+				t.Errorf("Body (%s) doesn't meet the expected result (%s)", result.Body.String(), testdata.expected)
+			}
+		})
+	}
+}
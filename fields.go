@@ -0,0 +1,167 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// Fieldset is the tree of JSON properties requested through the `fields=a,b.c` query parameter.
+// An empty subtree selects the whole property.
+type Fieldset map[string]Fieldset
+
+func ParseFields(params url.Values) (Fieldset, error) {
+	fieldsStr := params.Get("fields")
+	if "" == fieldsStr {
+		return nil, nil
+	}
+	return parsePaths(fieldsStr)
+}
+
+// parsePaths turns a comma separated list of dotted paths into a tree.
+func parsePaths(pathsStr string) (Fieldset, error) {
+	fieldset := make(Fieldset)
+	for _, path := range strings.Split(pathsStr, ",") {
+		path = strings.TrimSpace(path)
+		if "" == path {
+			return nil, NewError(KindValidation, nil, "Empty path in '%s'", pathsStr)
+		}
+		current := fieldset
+		for _, property := range strings.Split(path, ".") {
+			if "" == property {
+				return nil, NewError(KindValidation, nil, "Invalid path '%s'", path)
+			}
+			if nil == current[property] {
+				current[property] = make(Fieldset)
+			}
+			current = current[property]
+		}
+	}
+	return fieldset, nil
+}
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// Validate checks that every requested property exists in the JSON representation of entityType.
+func (f Fieldset) Validate(entityType reflect.Type) error {
+	return f.validate(entityType, "")
+}
+
+func (f Fieldset) validate(t reflect.Type, parent string) error {
+	if 0 == len(f) {
+		return nil
+	}
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType) {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Map, reflect.Interface:
+		return nil
+	case reflect.Struct:
+		properties := jsonProperties(t)
+		for property, sub := range f {
+			propertyType, found := properties[property]
+			if !found {
+				return NewError(KindValidation, nil, "Unknown field '%s'", parent+property)
+			}
+			if err := sub.validate(propertyType, parent+property+"."); nil != err {
+				return err
+			}
+		}
+		return nil
+	}
+	return NewError(KindValidation, nil, "Field '%s' has no sub field", strings.TrimSuffix(parent, "."))
+}
+
+// withoutExpandedRelations returns a copy of f where expanded relations have no sub field, as their sub fields belong to
+// the related entities rather than to the entity holding the relations.
+func (f Fieldset) withoutExpandedRelations(relations []Relation, expansion Expansion, prefix string) Fieldset {
+	trimmed := make(Fieldset, len(f))
+	for property, sub := range f {
+		path := prefix + property
+		subExpansion, expanded := expansion[property]
+		_, isRelation := findRelation(relations, path)
+		switch {
+		case !expanded:
+			trimmed[property] = sub
+		case isRelation:
+			trimmed[property] = make(Fieldset)
+		default:
+			trimmed[property] = sub.withoutExpandedRelations(relations, subExpansion, path+".")
+		}
+	}
+	return trimmed
+}
+
+func jsonProperties(t reflect.Type) map[string]reflect.Type {
+	properties := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		name := strings.Split(tag, ",")[0]
+		if "-" == tag {
+			continue
+		}
+		if field.Anonymous && "" == name {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				for property, propertyType := range jsonProperties(embedded) {
+					if _, shadowed := properties[property]; !shadowed {
+						properties[property] = propertyType
+					}
+				}
+				continue
+			}
+		}
+		if "" != field.PkgPath {
+			continue
+		}
+		if "" == name {
+			name = field.Name
+		}
+		properties[name] = field.Type
+	}
+	return properties
+}
+
+// Apply returns the JSON representation of entity trimmed to the requested properties.
+func (f Fieldset) Apply(entity Entity) (interface{}, error) {
+	if 0 == len(f) {
+		return entity, nil
+	}
+	representation, err := numberPreservingRepresentation(entity)
+	if err != nil {
+		return nil, err
+	}
+	return f.project(representation), nil
+}
+
+func (f Fieldset) project(representation interface{}) interface{} {
+	if 0 == len(f) {
+		return representation
+	}
+	switch typed := representation.(type) {
+	case map[string]interface{}:
+		projected := make(map[string]interface{})
+		for property, sub := range f {
+			if value, found := typed[property]; found {
+				projected[property] = sub.project(value)
+			}
+		}
+		return projected
+	case []interface{}:
+		projected := make([]interface{}, 0, len(typed))
+		for _, element := range typed {
+			projected = append(projected, f.project(element))
+		}
+		return projected
+	}
+	return representation
+}
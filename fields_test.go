@@ -0,0 +1,102 @@
+package rest_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/normegil/rest"
+)
+
+type fieldsOwner struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type fieldsEntity struct {
+	ID     string            `json:"id"`
+	Title  string            `json:"title"`
+	Owner  fieldsOwner       `json:"owner"`
+	Tags   []fieldsOwner     `json:"tags"`
+	Extra  map[string]string `json:"extra"`
+	Secret string            `json:"-"`
+}
+
+func TestFieldset(t *testing.T) {
+	entity := fieldsEntity{
+		ID:    "1",
+		Title: "title",
+		Owner: fieldsOwner{Name: "owner", Email: "owner@example.com"},
+		Tags:  []fieldsOwner{{Name: "a", Email: "a@example.com"}},
+		Extra: map[string]string{"key": "value", "other": "value"},
+	}
+	testcases := []struct {
+		fields   string
+		valid    bool
+		expected string
+	}{
+		{"id,title", true, `{"id":"1","title":"title"}`},
+		{"owner.name", true, `{"owner":{"name":"owner"}}`},
+		{"owner", true, `{"owner":{"email":"owner@example.com","name":"owner"}}`},
+		{"tags.email", true, `{"tags":[{"email":"a@example.com"}]}`},
+		{"extra.key", true, `{"extra":{"key":"value"}}`},
+		{"unknown", false, ""},
+		{"Secret", false, ""},
+		{"owner.unknown", false, ""},
+		{"title.sub", false, ""},
+		{"id,,title", false, ""},
+	}
+	for _, testdata := range testcases {
+		t.Run(testdata.fields, func(t *testing.T) {
+			fieldset, err := rest.ParseFields(url.Values{"fields": []string{testdata.fields}})
+			if nil == err {
+				err = fieldset.Validate(reflect.TypeOf(entity))
+			}
+			if !testdata.valid {
+				if rest.KindValidation != rest.ErrorKindOf(err) {
+					t.Errorf("Expected a validation error but got %+v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			projected, err := fieldset.Apply(entity)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := json.Marshal(projected)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if testdata.expected != string(result) {
+				t.Errorf("Projection (%s) doesn't meet the expected result (%s)", string(result), testdata.expected)
+			}
+		})
+	}
+}
+
+func TestDefaultControllerGetWithFields(t *testing.T) {
+	dao := rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{})
+	id, err := dao.Set(testEntity{Name: "name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctrl := rest.NewController("things", dao, nil, newTestUnmarshaller)
+
+	result := httptest.NewRecorder()
+	ctrl.Get(result, httptest.NewRequest("GET", "http://localhost/things/"+id.String()+"?fields=name", nil), httprouter.Params{{Key: "id", Value: id.String()}})
+	if expected := `{"name":"name"}`; expected != result.Body.String() {
+		t.Errorf("Body (%s) doesn't meet the expected result (%s)", result.Body.String(), expected)
+	}
+
+	result = httptest.NewRecorder()
+	ctrl.Get(result, httptest.NewRequest("GET", "http://localhost/things/"+id.String()+"?fields=unknown", nil), httprouter.Params{{Key: "id", Value: id.String()}})
+	if http.StatusBadRequest != result.Code {
+		t.Errorf("Unknown field returned %d instead of %d", result.Code, http.StatusBadRequest)
+	}
+}
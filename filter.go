@@ -0,0 +1,223 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type Operator string
+
+const (
+	Equal          = Operator("eq")
+	NotEqual       = Operator("ne")
+	LowerThan      = Operator("lt")
+	LowerOrEqual   = Operator("lte")
+	GreaterThan    = Operator("gt")
+	GreaterOrEqual = Operator("gte")
+	In             = Operator("in")
+	Prefix         = Operator("prefix")
+)
+
+var operators = map[Operator]bool{
+	Equal: true, NotEqual: true, LowerThan: true, LowerOrEqual: true, GreaterThan: true, GreaterOrEqual: true, In: true, Prefix: true,
+}
+
+// Condition restricts a field. Values holds a single value for every operator except In.
+type Condition struct {
+	Field    string
+	Operator Operator
+	Values   []string
+}
+
+// Filter is a conjunction of conditions.
+type Filter []Condition
+
+// reservedParameters are the query parameters understood by DefaultController, never parsed as filters.
+var reservedParameters = map[string]bool{
+	"offset": true,
+	"limit":  true,
+	"expand": true,
+	"sort":   true,
+	"after":  true,
+	"before": true,
+	"fields": true,
+}
+
+// ParseFilter reads conditions written as `field=value` or `field[operator]=value` from the query parameters.
+// Parameters using the operator syntax have to target one of the allowed fields. Other parameters are only conditions
+// when they name an allowed field, and are ignored otherwise, as they may be meant for something else (e.g. a cache buster).
+func ParseFilter(params url.Values, allowedFields []string) (Filter, error) {
+	allowed := make(map[string]bool)
+	for _, field := range allowedFields {
+		allowed[field] = true
+	}
+	filter := make(Filter, 0)
+	for key, values := range params {
+		if reservedParameters[key] {
+			continue
+		}
+		field := key
+		operator := Equal
+		open := strings.Index(key, "[")
+		filterSyntax := open > 0 && strings.HasSuffix(key, "]")
+		if filterSyntax {
+			field = key[:open]
+			operator = Operator(key[open+1 : len(key)-1])
+		}
+		if !allowed[field] {
+			if !filterSyntax {
+				continue
+			}
+			return nil, NewError(KindValidation, nil, "Filtering on '%s' is not allowed", field)
+		}
+		if !operators[operator] {
+			return nil, NewError(KindValidation, nil, "Unknown filter operator '%s' on '%s'", operator, field)
+		}
+		for _, value := range values {
+			condition := Condition{Field: field, Operator: operator, Values: []string{value}}
+			if In == operator {
+				condition.Values = strings.Split(value, ",")
+			}
+			filter = append(filter, condition)
+		}
+	}
+	return filter, nil
+}
+
+// Matches evaluates the filter against the JSON representation of entity.
+func (f Filter) Matches(entity Entity) (bool, error) {
+	if 0 == len(f) {
+		return true, nil
+	}
+	representation, err := jsonRepresentation(entity)
+	if err != nil {
+		return false, err
+	}
+	return f.matches(representation), nil
+}
+
+func (f Filter) matches(representation interface{}) bool {
+	for _, condition := range f {
+		value, found := lookupPath(representation, condition.Field)
+		if !found || !condition.matches(value) {
+			return false
+		}
+	}
+	return true
+}
+
+func jsonRepresentation(entity Entity) (interface{}, error) {
+	jsonEntity, err := json.Marshal(entity)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Encoding entity into json '%+v'", entity)
+	}
+	var representation interface{}
+	if err = json.Unmarshal(jsonEntity, &representation); nil != err {
+		return nil, errors.Wrapf(err, "Decoding json representation %s", string(jsonEntity))
+	}
+	return representation, nil
+}
+
+func lookupPath(representation interface{}, path string) (interface{}, bool) {
+	current := representation
+	for _, property := range strings.Split(path, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = object[property]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func (c Condition) matches(value interface{}) bool {
+	switch c.Operator {
+	case In:
+		for _, expected := range c.Values {
+			if 0 == compareJSONValue(value, expected) {
+				return true
+			}
+		}
+		return false
+	case Prefix:
+		str, ok := value.(string)
+		return ok && strings.HasPrefix(str, c.Values[0])
+	}
+	comparison := compareJSONValue(value, c.Values[0])
+	switch c.Operator {
+	case Equal:
+		return 0 == comparison
+	case NotEqual:
+		return 0 != comparison
+	case LowerThan:
+		return comparison < 0
+	case LowerOrEqual:
+		return comparison <= 0
+	case GreaterThan:
+		return comparison > 0
+	case GreaterOrEqual:
+		return comparison >= 0
+	}
+	return false
+}
+
+// compareJSONValue compares a decoded JSON value with a query parameter, numerically when both are numbers.
+func compareJSONValue(value interface{}, expected string) int {
+	if number, ok := value.(float64); ok {
+		if expectedNumber, err := strconv.ParseFloat(expected, 64); nil == err {
+			switch {
+			case number < expectedNumber:
+				return -1
+			case number > expectedNumber:
+				return 1
+			}
+			return 0
+		}
+	}
+	var str string
+	switch typed := value.(type) {
+	case string:
+		str = typed
+	case nil:
+		str = "null"
+	default:
+		str = fmt.Sprint(typed)
+	}
+	return strings.Compare(str, expected)
+}
+
+// Query describes the subset of a collection requested by a client, and its order.
+// When Keyset is set, the collection is ordered by primary key, the offset is ignored and entities are still returned in ascending key order.
+type Query struct {
+	Filter     Filter
+	Sort       []SortField
+	Keyset     *Keyset
+	Pagination Pagination
+}
+
+func (q Query) isDefault() bool {
+	return 0 == len(q.Filter) && 0 == len(q.Sort) && nil == q.Keyset
+}
+
+func (q Query) validate() error {
+	if nil != q.Keyset && 0 != len(q.Sort) {
+		return NewError(KindValidation, nil, "Sorting cannot be combined with cursors")
+	}
+	return nil
+}
+
+// FilterableDAO is implemented by DAOs able to filter and sort collections as described by a Query.
+type FilterableDAO interface {
+	GetAllEntitiesMatching(context.Context, Query) ([]Entity, error)
+	GetAllIDsMatching(context.Context, Query) ([]Identifier, error)
+	TotalNumberOfEntitiesMatching(context.Context, Filter) (int64, error)
+}
@@ -0,0 +1,205 @@
+package rest_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/normegil/rest"
+)
+
+func TestParseFilterValidation(t *testing.T) {
+	testcases := []struct {
+		query      string
+		valid      bool
+		conditions int
+	}{
+		{"name=a", true, 1},
+		{"offset=1&limit=2&expand=true", true, 0},
+		{"size[gte]=2&name[prefix]=ent", true, 2},
+		{"name[in]=a,b", true, 1},
+		{"unknown=a&name=b", true, 1},
+		{"unknown[eq]=a", false, 0},
+		{"size[between]=1", false, 0},
+	}
+	for _, testdata := range testcases {
+		t.Run(testdata.query, func(t *testing.T) {
+			params, err := url.ParseQuery(testdata.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			filter, err := rest.ParseFilter(params, []string{"name", "size"})
+			if testdata.valid && nil != err {
+				t.Errorf("Expected a valid filter but got %+v", err)
+			}
+			if testdata.valid && testdata.conditions != len(filter) {
+				t.Errorf("Conditions (%+v) don't meet the expected result (%d conditions)", filter, testdata.conditions)
+			}
+			if !testdata.valid && rest.KindValidation != rest.ErrorKindOf(err) {
+				t.Errorf("Expected a validation error but got %+v", err)
+			}
+		})
+	}
+}
+
+func TestFilterableDAOs(t *testing.T) {
+	mapper, err := rest.NewStructMapper(sqlEntity{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	daos := map[string]rest.DAO{
+		"Memory":   rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{}),
+		"Database": newSQLiteDAO(t, mapper, rest.NewMapperQueries(rest.SQLite, "things", mapper)),
+	}
+	for _, dao := range daos {
+		for i := 0; i < 10; i++ {
+			name := "entity-" + strconv.Itoa(i)
+			if i%2 == 0 {
+				name = "even_" + strconv.Itoa(i)
+			}
+			if _, err := dao.Set(sqlEntity{Name: name, Size: int64(i)}); nil != err {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	testcases := []struct {
+		query      string
+		nbExpected int64
+	}{
+		{"size=3", 1},
+		{"size[ne]=3", 9},
+		{"size[lt]=3", 3},
+		{"size[lte]=3", 4},
+		{"size[gt]=3", 6},
+		{"size[gte]=3&size[lt]=5", 2},
+		{"name[in]=entity-1,entity-3,unknown", 2},
+		{"name[prefix]=even_", 5},
+		{"name[prefix]=even%25", 0},
+		{"name=even_2", 1},
+	}
+	for daoName, dao := range daos {
+		filterable := dao.(rest.FilterableDAO)
+		for _, testdata := range testcases {
+			t.Run(daoName+": "+testdata.query, func(t *testing.T) {
+				params, err := url.ParseQuery(testdata.query)
+				if err != nil {
+					t.Fatal(err)
+				}
+				filter, err := rest.ParseFilter(params, []string{"name", "size"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				total, err := filterable.TotalNumberOfEntitiesMatching(context.Background(), filter)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if testdata.nbExpected != total {
+					t.Errorf("Total (%d) doesn't meet the expected result (%d)", total, testdata.nbExpected)
+				}
+				query := rest.Query{Filter: filter}
+				query.Pagination.SetLimit(2)
+				ids, err := filterable.GetAllIDsMatching(context.Background(), query)
+				if err != nil {
+					t.Fatal(err)
+				}
+				expected := testdata.nbExpected
+				if expected > 2 {
+					expected = 2
+				}
+				if expected != int64(len(ids)) {
+					t.Errorf("Number of IDs (%d) doesn't meet the expected result (%d)", len(ids), expected)
+				}
+				entities, err := filterable.GetAllEntitiesMatching(context.Background(), query)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(ids) != len(entities) {
+					t.Errorf("Number of entities (%d) doesn't meet the number of IDs (%d)", len(entities), len(ids))
+				}
+			})
+		}
+	}
+}
+
+// propertyEntity names its JSON properties differently from its columns.
+type propertyEntity struct {
+	Identifier string `db:"id,pk" json:"id"`
+	Name       string `db:"name" json:"name"`
+	CreatedAt  int64  `db:"created_at" json:"createdAt"`
+}
+
+func (e propertyEntity) ID() rest.Identifier {
+	if "" == e.Identifier {
+		return nil
+	}
+	return rest.StringIdentifier(e.Identifier)
+}
+
+func (e propertyEntity) WithID(id rest.Identifier) (rest.IdentifiableEntity, error) {
+	e.Identifier = id.String()
+	return e, nil
+}
+
+func TestDefaultControllerFiltersPropertiesOfBothDAOs(t *testing.T) {
+	mapper, err := rest.NewStructMapper(propertyEntity{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if _, err = db.Exec(`CREATE TABLE things (id TEXT PRIMARY KEY, name TEXT NOT NULL, created_at INTEGER NOT NULL)`); nil != err {
+		t.Fatal(err)
+	}
+	databaseDAO, err := rest.NewDatabaseDAO(db, mapper, rest.NewMapperQueries(rest.SQLite, "things", mapper), rest.UUIDIdentifierGenerator{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer databaseDAO.Close()
+	daos := map[string]rest.DAO{
+		"Memory":   rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{}),
+		"Database": databaseDAO,
+	}
+	for daoName, dao := range daos {
+		t.Run(daoName, func(t *testing.T) {
+			for i := 0; i < 5; i++ {
+				if _, err := dao.Set(propertyEntity{Name: "entity-" + strconv.Itoa(i), CreatedAt: int64(i)}); nil != err {
+					t.Fatal(err)
+				}
+			}
+			ctrl := rest.NewController("things", dao, nil, nil)
+			ctrl.FilterableFields = []string{"createdAt"}
+			ctrl.SortableFields = []string{"createdAt"}
+			ctrl.BareCollections = true
+
+			result := httptest.NewRecorder()
+			ctrl.GetAll(result, httptest.NewRequest("GET", "http://localhost/things?expand=true&createdAt[gte]=2&sort=-createdAt", nil), httprouter.Params{})
+			if http.StatusOK != result.Code {
+				t.Fatalf("GetAll returned %d: %s", result.Code, result.Body.String())
+			}
+			var items []propertyEntity
+			if err := json.Unmarshal(result.Body.Bytes(), &items); nil != err {
+				t.Fatal(err)
+			}
+			expected := []string{"entity-4", "entity-3", "entity-2"}
+			if len(expected) != len(items) {
+				t.Fatalf("Items (%+v) don't meet the expected names (%v)", items, expected)
+			}
+			for i, name := range expected {
+				if name != items[i].Name {
+					t.Errorf("Item %d (%s) doesn't meet the expected result (%s)", i, items[i].Name, name)
+				}
+			}
+		})
+	}
+}
@@ -1,10 +1,64 @@
 package rest
 
-import "net/http"
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
 
+// DefaultHeaders answers with Content-Type: application/json when the handler doesn't negotiate another media type.
+// Flushing, hijacking (e.g. for WebSockets) and HTTP/2 push stay available to the handler.
 func DefaultHeaders(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		h.ServeHTTP(w, r)
+		h.ServeHTTP(&defaultContentTypeWriter{ResponseWriter: w}, r)
 	})
 }
+
+type defaultContentTypeWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *defaultContentTypeWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if _, set := w.Header()["Content-Type"]; !set {
+			w.Header().Set("Content-Type", JSONMediaType)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *defaultContentTypeWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *defaultContentTypeWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *defaultContentTypeWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	return hijacker.Hijack()
+}
+
+func (w *defaultContentTypeWriter) Push(target string, opts *http.PushOptions) error {
+	pusher, ok := w.ResponseWriter.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+	return pusher.Push(target, opts)
+}
+
+// Unwrap gives http.ResponseController access to the wrapped writer.
+func (w *defaultContentTypeWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,93 @@
+package rest_test
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/normegil/rest"
+)
+
+func TestDefaultHeadersContentType(t *testing.T) {
+	testcases := []struct {
+		name     string
+		handler  http.HandlerFunc
+		expected string
+	}{
+		{"Default", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("{}")) }, rest.JSONMediaType},
+		{"Negotiated", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/xml")
+			w.Write([]byte("<a/>"))
+		}, "application/xml"},
+		{"Status only", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }, rest.JSONMediaType},
+	}
+	for _, testdata := range testcases {
+		t.Run(testdata.name, func(t *testing.T) {
+			result := httptest.NewRecorder()
+			rest.DefaultHeaders(testdata.handler).ServeHTTP(result, httptest.NewRequest("GET", "http://localhost/", nil))
+			if testdata.expected != result.Header().Get("Content-Type") {
+				t.Errorf("Content-Type (%s) doesn't meet the expected result (%s)", result.Header().Get("Content-Type"), testdata.expected)
+			}
+		})
+	}
+}
+
+func TestDefaultHeadersHijack(t *testing.T) {
+	server := httptest.NewServer(rest.DefaultHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			t.Error("Response writer cannot be hijacked")
+			return
+		}
+		conn, buffer, err := hijacker.Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		buffer.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\nhijacked")
+		buffer.Flush()
+	})))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n")); nil != err {
+		t.Fatal(err)
+	}
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if http.StatusSwitchingProtocols != response.StatusCode {
+		t.Errorf("Status (%d) doesn't meet the expected result (%d)", response.StatusCode, http.StatusSwitchingProtocols)
+	}
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if "hijacked" != string(body) {
+		t.Errorf("Body (%s) doesn't meet the expected result (%s)", string(body), "hijacked")
+	}
+}
+
+func TestDefaultHeadersPushWithoutHTTP2(t *testing.T) {
+	var pushErr error
+	rest.DefaultHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pusher, ok := w.(http.Pusher)
+		if !ok {
+			t.Fatal("Response writer cannot push")
+		}
+		pushErr = pusher.Push("/style.css", nil)
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost/", nil))
+	if http.ErrNotSupported != pushErr {
+		t.Errorf("Expected %+v but got %+v", http.ErrNotSupported, pushErr)
+	}
+}
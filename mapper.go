@@ -0,0 +1,227 @@
+package rest
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const dbTag = "db"
+
+// StructMapper maps rows to entities using the `db:"column"` tags of their struct fields.
+// The primary key is the field tagged `db:"column,pk"`, or the column named "id". The field tagged `db:"column,version"`
+// holds the version of VersionedEntity.
+// Columns() lists the other columns in field order, then the version column, followed by the primary key; ToSlice follows the same order.
+type StructMapper struct {
+	entityType    reflect.Type
+	pointer       bool
+	columns       []string
+	fields        map[string][]int
+	properties    map[string]string
+	keyColumn     string
+	versionColumn string
+}
+
+func NewStructMapper(prototype Entity) (*StructMapper, error) {
+	entityType := reflect.TypeOf(prototype)
+	if nil == entityType {
+		return nil, fmt.Errorf("Cannot map a nil prototype")
+	}
+	pointer := entityType.Kind() == reflect.Ptr
+	if pointer {
+		entityType = entityType.Elem()
+	}
+	if entityType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Cannot map %s: only structs are supported", entityType)
+	}
+
+	m := &StructMapper{
+		entityType: entityType,
+		pointer:    pointer,
+		fields:     make(map[string][]int),
+		properties: make(map[string]string),
+	}
+	if err := m.loadFields(entityType, nil, ""); nil != err {
+		return nil, err
+	}
+	if "" == m.keyColumn {
+		if _, found := m.fields["id"]; !found {
+			return nil, fmt.Errorf("No primary key in %s: tag a field with `db:\"<column>,pk\"`", entityType)
+		}
+		m.keyColumn = "id"
+	}
+	if m.versionColumn == m.keyColumn && "" != m.versionColumn {
+		return nil, fmt.Errorf("Primary key '%s' of %s cannot be its version", m.keyColumn, entityType)
+	}
+	m.columns = orderColumns(m.columns, m.keyColumn, m.versionColumn)
+	return m, nil
+}
+
+// loadFields maps the tagged fields of structType, whose JSON properties are prefixed by propertyPrefix.
+func (m *StructMapper) loadFields(structType reflect.Type, parentIndex []int, propertyPrefix string) error {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		index := append(append([]int{}, parentIndex...), i)
+		property, hasProperty := jsonProperty(field)
+		tag, tagged := field.Tag.Lookup(dbTag)
+		if !tagged {
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				prefix := propertyPrefix
+				if _, named := field.Tag.Lookup("json"); named && hasProperty {
+					prefix += property + "."
+				}
+				if err := m.loadFields(field.Type, index, prefix); nil != err {
+					return err
+				}
+			}
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		column := parts[0]
+		if "-" == column || "" == column {
+			continue
+		}
+		if "" != field.PkgPath {
+			return fmt.Errorf("Field %s.%s mapped to column '%s' is not exported", structType, field.Name, column)
+		}
+		if _, duplicate := m.fields[column]; duplicate {
+			return fmt.Errorf("Column '%s' is mapped to several fields of %s", column, m.entityType)
+		}
+		for _, option := range parts[1:] {
+			if "pk" == option {
+				if "" != m.keyColumn {
+					return fmt.Errorf("Several primary keys in %s: '%s' and '%s'", m.entityType, m.keyColumn, column)
+				}
+				m.keyColumn = column
+			}
+			if "version" == option {
+				if "" != m.versionColumn {
+					return fmt.Errorf("Several version columns in %s: '%s' and '%s'", m.entityType, m.versionColumn, column)
+				}
+				m.versionColumn = column
+			}
+		}
+		m.fields[column] = index
+		m.columns = append(m.columns, column)
+		if hasProperty {
+			m.properties[propertyPrefix+property] = column
+		}
+	}
+	return nil
+}
+
+// jsonProperty returns the name of field in JSON representations, as encoding/json names it.
+func jsonProperty(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if "-" == tag {
+		return "", false
+	}
+	if name := strings.Split(tag, ",")[0]; "" != name {
+		return name, true
+	}
+	return field.Name, true
+}
+
+func (m *StructMapper) Columns() []string {
+	return append([]string{}, m.columns...)
+}
+
+func (m *StructMapper) KeyColumn() string {
+	return m.keyColumn
+}
+
+// PropertyColumns maps the JSON properties of the mapped fields, as filters and sorts name them, to their column.
+func (m *StructMapper) PropertyColumns() map[string]string {
+	properties := make(map[string]string, len(m.properties))
+	for property, column := range m.properties {
+		properties[property] = column
+	}
+	return properties
+}
+
+// VersionColumn returns the column tagged as version, or an empty string.
+func (m *StructMapper) VersionColumn() string {
+	return m.versionColumn
+}
+
+func (m *StructMapper) ToEntities(rows *sql.Rows) ([]Entity, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Listing result set columns")
+	}
+	indexes := make([][]int, 0, len(columns))
+	for _, column := range columns {
+		index, found := m.fields[column]
+		if !found {
+			return nil, fmt.Errorf("Column '%s' has no matching field in %s", column, m.entityType)
+		}
+		indexes = append(indexes, index)
+	}
+
+	entities := make([]Entity, 0)
+	for rows.Next() {
+		entity := reflect.New(m.entityType)
+		destinations := make([]interface{}, 0, len(indexes))
+		for _, index := range indexes {
+			destinations = append(destinations, entity.Elem().FieldByIndex(index).Addr().Interface())
+		}
+		if err := rows.Scan(destinations...); nil != err {
+			return nil, errors.Wrapf(err, "Scanning row into %s", m.entityType)
+		}
+		if m.pointer {
+			entities = append(entities, entity.Interface())
+		} else {
+			entities = append(entities, entity.Elem().Interface())
+		}
+	}
+	return entities, nil
+}
+
+func (m *StructMapper) ToIdentifiers(rows *sql.Rows) ([]Identifier, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Listing result set columns")
+	}
+	if 1 != len(columns) || m.keyColumn != columns[0] {
+		return nil, fmt.Errorf("Expected only primary key column '%s' but got %v", m.keyColumn, columns)
+	}
+	keyType := m.entityType.FieldByIndex(m.fields[m.keyColumn]).Type
+
+	identifiers := make([]Identifier, 0)
+	for rows.Next() {
+		key := reflect.New(keyType)
+		if err := rows.Scan(key.Interface()); nil != err {
+			return nil, errors.Wrapf(err, "Scanning primary key '%s'", m.keyColumn)
+		}
+		identifiers = append(identifiers, toIdentifier(key.Elem().Interface()))
+	}
+	return identifiers, nil
+}
+
+func toIdentifier(key interface{}) Identifier {
+	if identifier, ok := key.(Identifier); ok {
+		return identifier
+	}
+	return StringIdentifier(fmt.Sprint(key))
+}
+
+func (m *StructMapper) ToSlice(entity Entity) ([]interface{}, error) {
+	value := reflect.ValueOf(entity)
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil, fmt.Errorf("Cannot map a nil %s", m.entityType)
+		}
+		value = value.Elem()
+	}
+	if value.Type() != m.entityType {
+		return nil, fmt.Errorf("Expected entity of type %s but got %s", m.entityType, value.Type())
+	}
+	fields := make([]interface{}, 0, len(m.columns))
+	for _, column := range m.columns {
+		fields = append(fields, value.FieldByIndex(m.fields[column]).Interface())
+	}
+	return fields, nil
+}
@@ -0,0 +1,109 @@
+package rest_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/normegil/rest"
+)
+
+type mappedBase struct {
+	Created string `db:"created"`
+}
+
+type mappedEntity struct {
+	mappedBase
+	Name    string `db:"name"`
+	Key     string `db:"key,pk"`
+	Ignored string `db:"-"`
+	Size    int    `db:"size"`
+	Other   string
+}
+
+func TestStructMapperColumns(t *testing.T) {
+	mapper, err := rest.NewStructMapper(&mappedEntity{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"created", "name", "size", "key"}
+	if !reflect.DeepEqual(expected, mapper.Columns()) {
+		t.Errorf("Columns (%v) doesn't meet the expected result (%v)", mapper.Columns(), expected)
+	}
+	if "key" != mapper.KeyColumn() {
+		t.Errorf("Key column (%s) doesn't meet the expected result (%s)", mapper.KeyColumn(), "key")
+	}
+	slice, err := mapper.ToSlice(mappedEntity{mappedBase: mappedBase{"today"}, Name: "name", Key: "1", Size: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []interface{}{"today", "name", 3, "1"}; !reflect.DeepEqual(expected, slice) {
+		t.Errorf("Slice (%v) doesn't meet the expected result (%v)", slice, expected)
+	}
+	if _, err := mapper.ToSlice(testEntity{}); nil == err {
+		t.Errorf("Expected an error when mapping an entity of another type")
+	}
+}
+
+func TestStructMapperInvalidPrototypes(t *testing.T) {
+	testcases := []struct {
+		name      string
+		prototype rest.Entity
+	}{
+		{"Nil", nil},
+		{"Not a struct", "entity"},
+		{"No primary key", struct {
+			Name string `db:"name"`
+		}{}},
+		{"Several primary keys", struct {
+			ID   string `db:"id,pk"`
+			Code string `db:"code,pk"`
+		}{}},
+		{"Duplicated column", struct {
+			ID   string `db:"id"`
+			Name string `db:"name"`
+			Alt  string `db:"name"`
+		}{}},
+		{"Unexported field", struct {
+			ID   string `db:"id"`
+			name string `db:"name"`
+		}{}},
+		{"Several version columns", struct {
+			ID       string `db:"id"`
+			Version  int64  `db:"version,version"`
+			Revision int64  `db:"revision,version"`
+		}{}},
+		{"Versioned primary key", struct {
+			ID string `db:"id,pk,version"`
+		}{}},
+	}
+	for _, testdata := range testcases {
+		t.Run(testdata.name, func(t *testing.T) {
+			if _, err := rest.NewStructMapper(testdata.prototype); nil == err {
+				t.Errorf("Expected an error for prototype %+v", testdata.prototype)
+			}
+		})
+	}
+}
+
+func TestStructMapperPropertyColumns(t *testing.T) {
+	type audit struct {
+		CreatedAt int64 `db:"created_at" json:"createdAt"`
+	}
+	type entity struct {
+		audit
+		ID     string `db:"id,pk"`
+		Name   string `db:"name" json:"name,omitempty"`
+		Secret string `db:"secret" json:"-"`
+		Owner  struct {
+			Name string `db:"owner_name" json:"name"`
+		} `json:"owner"`
+	}
+	mapper, err := rest.NewStructMapper(entity{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"createdAt": "created_at", "ID": "id", "name": "name"}
+	if properties := mapper.PropertyColumns(); !reflect.DeepEqual(expected, properties) {
+		t.Errorf("Properties (%v) don't meet the expected result (%v)", properties, expected)
+	}
+}
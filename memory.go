@@ -0,0 +1,238 @@
+package rest
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// MemoryDAO keeps entities in insertion order, in memory. It is safe for concurrent use.
+type MemoryDAO struct {
+	lock        sync.RWMutex
+	txLock      sync.Mutex
+	idGenerator IdentifierGenerator
+	ids         []string
+	entities    map[string]IdentifiableEntity
+}
+
+func NewMemoryDAO(idGenerator IdentifierGenerator) *MemoryDAO {
+	return &MemoryDAO{
+		idGenerator: idGenerator,
+		entities:    make(map[string]IdentifiableEntity),
+	}
+}
+
+type memoryTransactionKey struct {
+	dao *MemoryDAO
+}
+
+// InTransaction runs transactions one after the other, so that checks made in fn still hold when it writes.
+// Changes aren't rolled back when fn fails, and writes made outside of InTransaction aren't held back.
+func (d *MemoryDAO) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	key := memoryTransactionKey{dao: d}
+	if nil != ctx.Value(key) {
+		return fn(ctx)
+	}
+	d.txLock.Lock()
+	defer d.txLock.Unlock()
+	return fn(context.WithValue(ctx, key, true))
+}
+
+func (d *MemoryDAO) GetAllEntities(p Pagination) ([]Entity, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	entities := make([]Entity, 0)
+	for _, id := range page(d.ids, p) {
+		entities = append(entities, d.entities[id])
+	}
+	return entities, nil
+}
+
+func (d *MemoryDAO) GetAllIDs(p Pagination) ([]Identifier, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	identifiers := make([]Identifier, 0)
+	for _, id := range page(d.ids, p) {
+		identifiers = append(identifiers, d.entities[id].ID())
+	}
+	return identifiers, nil
+}
+
+func page(ids []string, p Pagination) []string {
+	nbIDs := int64(len(ids))
+	start := p.Offset()
+	if start > nbIDs {
+		start = nbIDs
+	}
+	end := nbIDs
+	if p.Limit() < nbIDs-start {
+		end = start + p.Limit()
+	}
+	return ids[start:end]
+}
+
+func (d *MemoryDAO) matching(query Query) ([]string, error) {
+	if query.isDefault() {
+		return d.ids, nil
+	}
+	if err := query.validate(); nil != err {
+		return nil, err
+	}
+	ids := make([]string, 0)
+	representations := make(map[string]interface{})
+	for _, id := range d.ids {
+		representation, err := jsonRepresentation(d.entities[id])
+		if err != nil {
+			return nil, errors.Wrapf(err, "Filtering '%s'", id)
+		}
+		if query.Filter.matches(representation) && (nil == query.Keyset || query.Keyset.includes(d.entities[id].ID())) {
+			ids = append(ids, id)
+			representations[id] = representation
+		}
+	}
+	if nil != query.Keyset {
+		sort.SliceStable(ids, func(i, j int) bool {
+			return compareKey(d.entities[ids[i]].ID(), ids[j]) < 0
+		})
+	}
+	sortRepresentations(ids, representations, query.Sort)
+	return ids, nil
+}
+
+func pageQuery(ids []string, query Query) []string {
+	if nil == query.Keyset {
+		return page(ids, query.Pagination)
+	}
+	limit := query.Pagination.Limit()
+	if int64(len(ids)) <= limit {
+		return ids
+	}
+	if query.Keyset.Backward {
+		return ids[int64(len(ids))-limit:]
+	}
+	return ids[:limit]
+}
+
+func (d *MemoryDAO) GetAllEntitiesMatching(_ context.Context, query Query) ([]Entity, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	ids, err := d.matching(query)
+	if err != nil {
+		return nil, err
+	}
+	entities := make([]Entity, 0)
+	for _, id := range pageQuery(ids, query) {
+		entities = append(entities, d.entities[id])
+	}
+	return entities, nil
+}
+
+func (d *MemoryDAO) GetAllIDsMatching(_ context.Context, query Query) ([]Identifier, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	ids, err := d.matching(query)
+	if err != nil {
+		return nil, err
+	}
+	identifiers := make([]Identifier, 0)
+	for _, id := range pageQuery(ids, query) {
+		identifiers = append(identifiers, d.entities[id].ID())
+	}
+	return identifiers, nil
+}
+
+func (d *MemoryDAO) TotalNumberOfEntitiesMatching(_ context.Context, filter Filter) (int64, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	ids, err := d.matching(Query{Filter: filter})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(ids)), nil
+}
+
+func (d *MemoryDAO) TotalNumberOfEntities() (int64, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return int64(len(d.ids)), nil
+}
+
+func (d *MemoryDAO) Get(id Identifier) (Entity, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	entity, found := d.entities[id.String()]
+	if !found {
+		return nil, NotFoundError{ID: id}
+	}
+	return entity, nil
+}
+
+func (d *MemoryDAO) GetBatch(_ context.Context, ids []Identifier) (map[string]Entity, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	entities := make(map[string]Entity)
+	for _, id := range ids {
+		if entity, found := d.entities[id.String()]; found {
+			entities[id.String()] = entity
+		}
+	}
+	return entities, nil
+}
+
+func (d *MemoryDAO) Set(entity IdentifiableEntity) (Identifier, error) {
+	if !hasID(entity) {
+		id, err := d.idGenerator.Generate(entity)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Generating Identifier")
+		}
+		entity, err = entity.WithID(id)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Setting ID")
+		}
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	key := entity.ID().String()
+	current, found := d.entities[key]
+	if versioned, ok := entity.(VersionedEntity); ok {
+		version := initialVersion
+		if found {
+			if stored, ok := current.(Versioned); !ok || stored.Version() != versioned.Version() {
+				return nil, VersionMismatchError{ID: entity.ID(), Version: versioned.Version()}
+			}
+			var err error
+			if version, err = nextVersion(versioned.Version()); nil != err {
+				return nil, err
+			}
+		}
+		var err error
+		if entity, err = withVersion(entity, version); nil != err {
+			return nil, err
+		}
+	}
+	if !found {
+		d.ids = append(d.ids, key)
+	}
+	d.entities[key] = entity
+	return entity.ID(), nil
+}
+
+func (d *MemoryDAO) Delete(id Identifier) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	key := id.String()
+	if _, found := d.entities[key]; !found {
+		return NotFoundError{ID: id}
+	}
+	delete(d.entities, key)
+	for i, existing := range d.ids {
+		if existing == key {
+			d.ids = append(d.ids[:i], d.ids[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package rest_test
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/normegil/rest"
+	"github.com/normegil/rest/resttest"
+)
+
+func TestMemoryDAO(t *testing.T) {
+	resttest.TestDAO(t, resttest.DAOFixture{
+		NewDAO: func(_ *testing.T) rest.DAO {
+			return rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{})
+		},
+		NewEntity: func(seed int) rest.IdentifiableEntity {
+			return testEntity{Name: "entity-" + strconv.Itoa(seed)}
+		},
+	})
+}
+
+type intIdentifier int64
+
+func (i intIdentifier) String() string {
+	return strconv.FormatInt(int64(i), 10)
+}
+
+// intKeyEntity has a numeric primary key, ordered numerically by databases.
+type intKeyEntity struct {
+	Key  int64  `db:"id,pk" json:"id"`
+	Name string `db:"name" json:"name"`
+}
+
+func (e intKeyEntity) ID() rest.Identifier {
+	return intIdentifier(e.Key)
+}
+
+func (e intKeyEntity) WithID(id rest.Identifier) (rest.IdentifiableEntity, error) {
+	key, err := strconv.ParseInt(id.String(), 10, 64)
+	e.Key = key
+	return e, err
+}
+
+func TestKeysetOrderOfNumericKeys(t *testing.T) {
+	mapper, err := rest.NewStructMapper(intKeyEntity{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if _, err = db.Exec(`CREATE TABLE things (id INTEGER PRIMARY KEY, name TEXT NOT NULL)`); nil != err {
+		t.Fatal(err)
+	}
+	databaseDAO, err := rest.NewDatabaseDAO(db, mapper, rest.NewMapperQueries(rest.SQLite, "things", mapper), rest.UUIDIdentifierGenerator{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer databaseDAO.Close()
+	daos := map[string]rest.DAO{
+		"Memory":   rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{}),
+		"Database": databaseDAO,
+	}
+	testcases := []struct {
+		name     string
+		keyset   rest.Keyset
+		expected []string
+	}{
+		{"First page", rest.Keyset{}, []string{"1", "2", "3"}},
+		{"After 9", rest.Keyset{Key: "9"}, []string{"10", "11", "12"}},
+		{"Before 10", rest.Keyset{Key: "10", Backward: true}, []string{"7", "8", "9"}},
+		{"Last page", rest.Keyset{Backward: true}, []string{"10", "11", "12"}},
+	}
+	for daoName, dao := range daos {
+		for _, key := range []int64{12, 9, 10, 1, 2, 11, 3, 4, 5, 6, 7, 8} {
+			if _, err := dao.Set(intKeyEntity{Key: key, Name: "entity-" + strconv.FormatInt(key, 10)}); nil != err {
+				t.Fatal(err)
+			}
+		}
+		for _, testdata := range testcases {
+			t.Run(daoName+": "+testdata.name, func(t *testing.T) {
+				keyset := testdata.keyset
+				query := rest.Query{Keyset: &keyset}
+				query.Pagination.SetLimit(3)
+				ids, err := dao.(rest.FilterableDAO).GetAllIDsMatching(context.Background(), query)
+				if err != nil {
+					t.Fatal(err)
+				}
+				keys := make([]string, 0, len(ids))
+				for _, id := range ids {
+					keys = append(keys, id.String())
+				}
+				if !reflect.DeepEqual(testdata.expected, keys) {
+					t.Errorf("Keys (%v) don't meet the expected result (%v)", keys, testdata.expected)
+				}
+			})
+		}
+	}
+}
+
+// slowGetDAO delays reads, so that concurrent checks made outside of a transaction all see the same entity.
+type slowGetDAO struct {
+	*rest.MemoryDAO
+}
+
+func (d slowGetDAO) Get(id rest.Identifier) (rest.Entity, error) {
+	time.Sleep(20 * time.Millisecond)
+	return d.MemoryDAO.Get(id)
+}
+
+func TestMemoryDAOConcurrentIfMatch(t *testing.T) {
+	dao := slowGetDAO{MemoryDAO: rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{})}
+	if _, err := dao.Set(testEntity{Identifier: "1", Name: "name"}); nil != err {
+		t.Fatal(err)
+	}
+	ctrl := rest.NewController("things", dao, nil, newTestUnmarshaller)
+	params := httprouter.Params{{Key: "id", Value: "1"}}
+	result := httptest.NewRecorder()
+	ctrl.Get(result, httptest.NewRequest("GET", "http://localhost/things/1", nil), params)
+	etag := result.Header().Get("ETag")
+
+	const nbUpdates = 4
+	statuses := make(chan int, nbUpdates)
+	var wg sync.WaitGroup
+	for i := 0; i < nbUpdates; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			request := httptest.NewRequest("PUT", "http://localhost/things/1", strings.NewReader(`{"name":"update-`+strconv.Itoa(i)+`"}`))
+			request.Header.Set("If-Match", etag)
+			result := httptest.NewRecorder()
+			ctrl.Update(result, request, params)
+			statuses <- result.Code
+		}(i)
+	}
+	wg.Wait()
+	close(statuses)
+	updated := 0
+	for status := range statuses {
+		if http.StatusOK == status {
+			updated++
+		} else if http.StatusPreconditionFailed != status {
+			t.Errorf("Update returned %d instead of %d or %d", status, http.StatusOK, http.StatusPreconditionFailed)
+		}
+	}
+	if 1 != updated {
+		t.Errorf("Updates (%d) with the same If-Match don't meet the expected result (1)", updated)
+	}
+}
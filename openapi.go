@@ -0,0 +1,265 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON Schema object, as embedded in OpenAPI documents.
+type Schema map[string]interface{}
+
+// Parameter documents a parameter of a route. In is "path", "query" or "header".
+type Parameter struct {
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Schema      Schema `json:"schema,omitempty"`
+}
+
+// CollectionOf describes a response listing the URLs of Item, or Item themselves when expanded,
+// in a CollectionResponse envelope unless Bare.
+type CollectionOf struct {
+	Item interface{}
+	Bare bool
+}
+
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type OpenAPIDocument struct {
+	OpenAPI    string                                 `json:"openapi"`
+	Info       OpenAPIInfo                            `json:"info"`
+	Paths      map[string]map[string]OpenAPIOperation `json:"paths"`
+	Components OpenAPIComponents                      `json:"components"`
+	// componentTypes detects different types sharing a name
+	componentTypes map[string]reflect.Type
+}
+
+type OpenAPIComponents struct {
+	Schemas map[string]Schema `json:"schemas,omitempty"`
+}
+
+type OpenAPIOperation struct {
+	Summary     string                     `json:"summary,omitempty"`
+	Description string                     `json:"description,omitempty"`
+	Parameters  []Parameter                `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]OpenAPIResponse `json:"responses"`
+}
+
+type OpenAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
+}
+
+type OpenAPIMediaType struct {
+	Schema Schema `json:"schema,omitempty"`
+}
+
+// NewOpenAPIDocument describes routes. Path parameters are documented even for routes without metadata.
+func NewOpenAPIDocument(info OpenAPIInfo, routes []Route) *OpenAPIDocument {
+	doc := &OpenAPIDocument{
+		OpenAPI:        "3.0.3",
+		Info:           info,
+		Paths:          make(map[string]map[string]OpenAPIOperation),
+		Components:     OpenAPIComponents{Schemas: make(map[string]Schema)},
+		componentTypes: make(map[string]reflect.Type),
+	}
+	for _, route := range routes {
+		routePath, parameters := openAPIPath(route.Path())
+		if _, found := doc.Paths[routePath]; !found {
+			doc.Paths[routePath] = make(map[string]OpenAPIOperation)
+		}
+		doc.Paths[routePath][strings.ToLower(string(route.Method()))] = doc.operation(MetadataOf(route), parameters)
+	}
+	return doc
+}
+
+func (doc *OpenAPIDocument) operation(metadata RouteMetadata, pathParameters []Parameter) OpenAPIOperation {
+	operation := OpenAPIOperation{
+		Summary:     metadata.Summary,
+		Description: metadata.Description,
+		Responses:   make(map[string]OpenAPIResponse),
+	}
+	for _, parameter := range pathParameters {
+		if !declaresParameter(metadata.Parameters, parameter) {
+			operation.Parameters = append(operation.Parameters, parameter)
+		}
+	}
+	operation.Parameters = append(operation.Parameters, metadata.Parameters...)
+
+	if nil != metadata.Request {
+		mediaTypes := metadata.RequestMediaTypes
+		if 0 == len(mediaTypes) {
+			mediaTypes = []string{JSONMediaType}
+		}
+		body := &OpenAPIRequestBody{Required: true, Content: make(map[string]OpenAPIMediaType)}
+		schema := doc.schema(metadata.Request)
+		for _, mediaType := range mediaTypes {
+			body.Content[mediaType] = OpenAPIMediaType{Schema: schema}
+		}
+		operation.RequestBody = body
+	}
+
+	status := metadata.Status
+	if 0 == status {
+		status = http.StatusOK
+	}
+	response := OpenAPIResponse{Description: http.StatusText(status)}
+	if nil != metadata.Response {
+		mediaTypes := metadata.ResponseMediaTypes
+		if 0 == len(mediaTypes) {
+			mediaTypes = []string{JSONMediaType}
+		}
+		response.Content = make(map[string]OpenAPIMediaType)
+		schema := doc.schema(metadata.Response)
+		for _, mediaType := range mediaTypes {
+			response.Content[mediaType] = OpenAPIMediaType{Schema: schema}
+		}
+	}
+	operation.Responses[strconv.Itoa(status)] = response
+	operation.Responses["default"] = OpenAPIResponse{
+		Description: "Error",
+		Content:     map[string]OpenAPIMediaType{ProblemMediaType: {Schema: doc.schema(Problem{})}},
+	}
+	return operation
+}
+
+func declaresParameter(parameters []Parameter, parameter Parameter) bool {
+	for _, declared := range parameters {
+		if declared.Name == parameter.Name && declared.In == parameter.In {
+			return true
+		}
+	}
+	return false
+}
+
+// openAPIPath turns httprouter parameters (:name, *name) into OpenAPI templates.
+func openAPIPath(routePath Path) (string, []Parameter) {
+	segments := strings.Split(string(routePath), "/")
+	parameters := make([]Parameter, 0)
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			name := segment[1:]
+			segments[i] = "{" + name + "}"
+			parameters = append(parameters, Parameter{Name: name, In: "path", Required: true, Schema: Schema{"type": "string"}})
+		}
+	}
+	return strings.Join(segments, "/"), parameters
+}
+
+func (doc *OpenAPIDocument) schema(value interface{}) Schema {
+	switch typed := value.(type) {
+	case Schema:
+		return typed
+	case CollectionOf:
+		itemSchema := Schema{"type": "string", "format": "uri"}
+		if nil != typed.Item {
+			itemSchema = Schema{"oneOf": []Schema{itemSchema, doc.schema(typed.Item)}}
+		}
+		items := Schema{"type": "array", "items": itemSchema}
+		if typed.Bare {
+			return items
+		}
+		envelope := doc.structSchema(reflect.TypeOf(CollectionResponse{}))
+		properties := envelope["properties"].(map[string]Schema)
+		properties["current"] = Schema{"type": "string", "format": "uri"}
+		properties["items"] = items
+		return envelope
+	}
+	return doc.typeSchema(reflect.TypeOf(value))
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (doc *OpenAPIDocument) typeSchema(t reflect.Type) Schema {
+	for nil != t && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if nil == t {
+		return Schema{}
+	}
+	if timeType == t {
+		return Schema{"type": "string", "format": "date-time"}
+	}
+	if t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType) {
+		return Schema{}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return Schema{"type": "integer", "format": "int32"}
+	case reflect.Int64:
+		return Schema{"type": "integer", "format": "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if reflect.Uint8 == t.Elem().Kind() {
+			return Schema{"type": "string", "format": "byte"}
+		}
+		return Schema{"type": "array", "items": doc.typeSchema(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": doc.typeSchema(t.Elem())}
+	case reflect.Struct:
+		if "" == t.Name() {
+			return doc.structSchema(t)
+		}
+		return Schema{"$ref": "#/components/schemas/" + doc.component(t)}
+	}
+	return Schema{}
+}
+
+// component registers the schema of a named struct once, before describing it so recursive types terminate.
+func (doc *OpenAPIDocument) component(t reflect.Type) string {
+	name := t.Name()
+	if known, found := doc.componentTypes[name]; found && known != t {
+		name = path.Base(t.PkgPath()) + "." + name
+	}
+	if _, found := doc.componentTypes[name]; found {
+		return name
+	}
+	doc.componentTypes[name] = t
+	doc.Components.Schemas[name] = Schema{}
+	doc.Components.Schemas[name] = doc.structSchema(t)
+	return name
+}
+
+func (doc *OpenAPIDocument) structSchema(t reflect.Type) Schema {
+	properties := make(map[string]Schema)
+	for property, propertyType := range jsonProperties(t) {
+		properties[property] = doc.typeSchema(propertyType)
+	}
+	return Schema{"type": "object", "properties": properties}
+}
+
+// openAPIHandler serves the document of routes, generated on each request to include routes registered later.
+func openAPIHandler(info OpenAPIInfo, routes func() []Route) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := json.Marshal(NewOpenAPIDocument(info, routes()))
+		if err != nil {
+			ProblemErrorHandler{}.Handle(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", JSONMediaType)
+		w.Write(body)
+	})
+}
@@ -0,0 +1,128 @@
+package rest_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/normegil/rest"
+)
+
+type treeNode struct {
+	Label    string     `json:"label"`
+	Children []treeNode `json:"children,omitempty"`
+	Hidden   string     `json:"-"`
+}
+
+func TestRouterOpenAPI(t *testing.T) {
+	router := rest.NewRouter()
+	ctrl := rest.NewController("things", rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{}), nil, newTestUnmarshaller)
+	ctrl.SortableFields = []string{"name"}
+	if err := router.Register(ctrl); nil != err {
+		t.Fatal(err)
+	}
+	if err := router.Register(routesController{basePath: "trees", routes: []rest.Route{
+		rest.NewRoute(rest.POST, "/trees/:forest", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {}).Describe(rest.RouteMetadata{
+			Summary:  "Plant a tree",
+			Request:  treeNode{},
+			Response: &treeNode{},
+			Status:   http.StatusCreated,
+		}),
+	}}); nil != err {
+		t.Fatal(err)
+	}
+	router.ServeOpenAPI("/openapi.json", rest.OpenAPIInfo{Title: "Test", Version: "1.0"})
+
+	result := httptest.NewRecorder()
+	router.Router.ServeHTTP(result, httptest.NewRequest("GET", "http://localhost/openapi.json", nil))
+	if http.StatusOK != result.Code {
+		t.Fatalf("Document returned %d: %s", result.Code, result.Body.String())
+	}
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			Summary    string `json:"summary"`
+			Parameters []struct {
+				Name string `json:"name"`
+				In   string `json:"in"`
+			} `json:"parameters"`
+			RequestBody *struct {
+				Content map[string]struct {
+					Schema map[string]interface{} `json:"schema"`
+				} `json:"content"`
+			} `json:"requestBody"`
+			Responses map[string]struct {
+				Content map[string]struct {
+					Schema map[string]interface{} `json:"schema"`
+				} `json:"content"`
+			} `json:"responses"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]map[string]interface{} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(result.Body.Bytes(), &doc); nil != err {
+		t.Fatal(err)
+	}
+
+	if "3.0.3" != doc.OpenAPI {
+		t.Errorf("OpenAPI version (%s) doesn't meet the expected result (%s)", doc.OpenAPI, "3.0.3")
+	}
+	if _, documented := doc.Paths["/openapi.json"]; documented {
+		t.Error("Document path shouldn't be documented")
+	}
+	for _, method := range []string{"get", "put", "patch", "delete"} {
+		if _, found := doc.Paths["/things/{id}"][method]; !found {
+			t.Errorf("Operation %s /things/{id} is missing", method)
+		}
+	}
+
+	list := doc.Paths["/things"]["get"]
+	names := make([]string, 0)
+	for _, parameter := range list.Parameters {
+		names = append(names, parameter.Name)
+	}
+	if expected := []string{"offset", "limit", "expand", "fields", "sort"}; !reflect.DeepEqual(expected, names) {
+		t.Errorf("Collection parameters (%v) don't meet the expected result (%v)", names, expected)
+	}
+	envelope := list.Responses["200"].Content[rest.JSONMediaType].Schema
+	properties, _ := envelope["properties"].(map[string]interface{})
+	if _, found := properties["totalNumberOfItems"]; !found {
+		t.Errorf("Collection response isn't described as a CollectionResponse: %+v", envelope)
+	}
+	if _, found := list.Responses["200"].Content[rest.CSVMediaType]; !found {
+		t.Error("Collections should be documented as available in CSV")
+	}
+	if _, found := doc.Paths["/things/{id}"]["get"].Responses["200"].Content[rest.CSVMediaType]; found {
+		t.Error("Entities shouldn't be documented as available in CSV")
+	}
+
+	entity := doc.Components.Schemas["testEntity"]
+	entityProperties, _ := entity["properties"].(map[string]interface{})
+	if 2 != len(entityProperties) || nil == entityProperties["id"] || nil == entityProperties["name"] {
+		t.Errorf("Entity schema doesn't list its JSON properties: %+v", entity)
+	}
+	if _, found := doc.Components.Schemas["Problem"]; !found {
+		t.Error("Problem schema is missing")
+	}
+
+	plant := doc.Paths["/trees/{forest}"]["post"]
+	if "Plant a tree" != plant.Summary || 1 != len(plant.Parameters) || "forest" != plant.Parameters[0].Name || "path" != plant.Parameters[0].In {
+		t.Errorf("Described route isn't documented: %+v", plant)
+	}
+	if ref := plant.RequestBody.Content[rest.JSONMediaType].Schema["$ref"]; "#/components/schemas/treeNode" != ref {
+		t.Errorf("Request schema reference (%v) doesn't meet the expected result", ref)
+	}
+	if _, found := plant.Responses["201"]; !found {
+		t.Errorf("Created response is missing: %+v", plant.Responses)
+	}
+	tree, _ := doc.Components.Schemas["treeNode"]["properties"].(map[string]interface{})
+	children, _ := tree["children"].(map[string]interface{})
+	items, _ := children["items"].(map[string]interface{})
+	if 2 != len(tree) || "#/components/schemas/treeNode" != items["$ref"] {
+		t.Errorf("Recursive schema isn't described through a reference: %+v", tree)
+	}
+}
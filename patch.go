@@ -0,0 +1,56 @@
+package rest
+
+import (
+	"fmt"
+	"mime"
+
+	jsonpatch "github.com/evanphx/json-patch"
+)
+
+const (
+	MergePatchMediaType = "application/merge-patch+json"
+	JSONPatchMediaType  = "application/json-patch+json"
+)
+
+type UnsupportedPatchError struct {
+	MediaType string
+}
+
+func (e UnsupportedPatchError) Error() string {
+	return fmt.Sprintf("Unsupported patch media type '%s'", e.MediaType)
+}
+
+func (e UnsupportedPatchError) ErrorKind() ErrorKind {
+	return KindUnsupportedMediaType
+}
+
+func (e UnsupportedPatchError) ErrorDetail() string {
+	return e.Error()
+}
+
+func applyPatch(contentType string, document []byte, patch []byte) ([]byte, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, UnsupportedPatchError{MediaType: contentType}
+	}
+	switch mediaType {
+	case MergePatchMediaType:
+		patched, err := jsonpatch.MergePatch(document, patch)
+		if err != nil {
+			return nil, NewError(KindValidation, err, "Invalid merge patch")
+		}
+		return patched, nil
+	case JSONPatchMediaType:
+		decoded, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return nil, NewError(KindValidation, err, "Invalid json patch")
+		}
+		patched, err := decoded.Apply(document)
+		if err != nil {
+			return nil, NewError(KindConflict, err, "Json patch cannot be applied to the current entity")
+		}
+		return patched, nil
+	default:
+		return nil, UnsupportedPatchError{MediaType: mediaType}
+	}
+}
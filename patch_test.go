@@ -0,0 +1,70 @@
+package rest_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/normegil/rest"
+)
+
+func TestDefaultControllerPatch(t *testing.T) {
+	testcases := []struct {
+		name        string
+		contentType string
+		patch       string
+		status      int
+		expected    testEntity
+	}{
+		{"Merge patch", rest.MergePatchMediaType, `{"name":"patched"}`, http.StatusOK, testEntity{Identifier: "1", Name: "patched"}},
+		{"Merge patch removal", rest.MergePatchMediaType, `{"name":null}`, http.StatusOK, testEntity{Identifier: "1"}},
+		{"Merge patch with charset", rest.MergePatchMediaType + "; charset=utf-8", `{"name":"patched"}`, http.StatusOK, testEntity{Identifier: "1", Name: "patched"}},
+		{"Json patch replace", rest.JSONPatchMediaType, `[{"op":"replace","path":"/name","value":"patched"}]`, http.StatusOK, testEntity{Identifier: "1", Name: "patched"}},
+		{"Json patch remove", rest.JSONPatchMediaType, `[{"op":"remove","path":"/name"}]`, http.StatusOK, testEntity{Identifier: "1"}},
+		{"Json patch add", rest.JSONPatchMediaType, `[{"op":"remove","path":"/name"},{"op":"add","path":"/name","value":"added"}]`, http.StatusOK, testEntity{Identifier: "1", Name: "added"}},
+		{"Json patch test", rest.JSONPatchMediaType, `[{"op":"test","path":"/name","value":"name"},{"op":"replace","path":"/name","value":"patched"}]`, http.StatusOK, testEntity{Identifier: "1", Name: "patched"}},
+		{"Json patch failed test", rest.JSONPatchMediaType, `[{"op":"test","path":"/name","value":"other"},{"op":"replace","path":"/name","value":"patched"}]`, http.StatusConflict, testEntity{Identifier: "1", Name: "name"}},
+		{"Invalid json patch", rest.JSONPatchMediaType, `{"op":"replace"}`, http.StatusBadRequest, testEntity{Identifier: "1", Name: "name"}},
+		{"Changing ID", rest.MergePatchMediaType, `{"id":"2"}`, http.StatusBadRequest, testEntity{Identifier: "1", Name: "name"}},
+		{"Unsupported media type", "application/json", `{"name":"patched"}`, http.StatusUnsupportedMediaType, testEntity{Identifier: "1", Name: "name"}},
+	}
+	for _, testdata := range testcases {
+		t.Run(testdata.name, func(t *testing.T) {
+			dao := rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{})
+			if _, err := dao.Set(testEntity{Identifier: "1", Name: "name"}); nil != err {
+				t.Fatal(err)
+			}
+			ctrl := rest.NewController("things", dao, nil, newTestUnmarshaller)
+			request := httptest.NewRequest("PATCH", "http://localhost/things/1", strings.NewReader(testdata.patch))
+			request.Header.Set("Content-Type", testdata.contentType)
+			result := httptest.NewRecorder()
+			ctrl.Patch(result, request, httprouter.Params{{Key: "id", Value: "1"}})
+			if testdata.status != result.Code {
+				t.Errorf("Patch returned %d instead of %d: %s", result.Code, testdata.status, result.Body.String())
+			}
+			if expected := rest.MergePatchMediaType + ", " + rest.JSONPatchMediaType; http.StatusUnsupportedMediaType == testdata.status && expected != result.Header().Get("Accept-Patch") {
+				t.Errorf("Accept-Patch (%s) doesn't meet the expected result (%s)", result.Header().Get("Accept-Patch"), expected)
+			}
+			stored, err := dao.Get(rest.StringIdentifier("1"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if testdata.expected != stored {
+				t.Errorf("Stored entity (%+v) doesn't meet the expected result (%+v)", stored, testdata.expected)
+			}
+		})
+	}
+}
+
+func TestDefaultControllerPatchUnknownEntity(t *testing.T) {
+	ctrl := rest.NewController("things", rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{}), nil, newTestUnmarshaller)
+	request := httptest.NewRequest("PATCH", "http://localhost/things/1", strings.NewReader(`{"name":"patched"}`))
+	request.Header.Set("Content-Type", rest.MergePatchMediaType)
+	result := httptest.NewRecorder()
+	ctrl.Patch(result, request, httprouter.Params{{Key: "id", Value: "1"}})
+	if http.StatusNotFound != result.Code {
+		t.Errorf("Patch returned %d instead of %d: %s", result.Code, http.StatusNotFound, result.Body.String())
+	}
+}
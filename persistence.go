@@ -1,8 +1,10 @@
 package rest
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 
 	"github.com/gofrs/uuid"
 	"github.com/pkg/errors"
@@ -23,12 +25,125 @@ type DAO interface {
 	Delete(Identifier) error
 }
 
+type ContextDAO interface {
+	GetAllEntitiesContext(context.Context, Pagination) ([]Entity, error)
+	GetAllIDsContext(context.Context, Pagination) ([]Identifier, error)
+	TotalNumberOfEntitiesContext(context.Context) (int64, error)
+	GetContext(context.Context, Identifier) (Entity, error)
+	SetContext(context.Context, IdentifiableEntity) (Identifier, error)
+	DeleteContext(context.Context, Identifier) error
+}
+
+// AsContextDAO returns dao itself when it is context aware, or an adapter checking the context before each call otherwise.
+func AsContextDAO(dao DAO) ContextDAO {
+	if ctxDAO, ok := dao.(ContextDAO); ok {
+		return ctxDAO
+	}
+	return daoContextAdapter{dao: dao}
+}
+
+type daoContextAdapter struct {
+	dao DAO
+}
+
+func (a daoContextAdapter) GetAllEntitiesContext(ctx context.Context, p Pagination) ([]Entity, error) {
+	if err := ctx.Err(); nil != err {
+		return nil, err
+	}
+	return a.dao.GetAllEntities(p)
+}
+
+func (a daoContextAdapter) GetAllIDsContext(ctx context.Context, p Pagination) ([]Identifier, error) {
+	if err := ctx.Err(); nil != err {
+		return nil, err
+	}
+	return a.dao.GetAllIDs(p)
+}
+
+func (a daoContextAdapter) TotalNumberOfEntitiesContext(ctx context.Context) (int64, error) {
+	if err := ctx.Err(); nil != err {
+		return 0, err
+	}
+	return a.dao.TotalNumberOfEntities()
+}
+
+func (a daoContextAdapter) GetContext(ctx context.Context, id Identifier) (Entity, error) {
+	if err := ctx.Err(); nil != err {
+		return nil, err
+	}
+	return a.dao.Get(id)
+}
+
+func (a daoContextAdapter) SetContext(ctx context.Context, entity IdentifiableEntity) (Identifier, error) {
+	if err := ctx.Err(); nil != err {
+		return nil, err
+	}
+	return a.dao.Set(entity)
+}
+
+func (a daoContextAdapter) DeleteContext(ctx context.Context, id Identifier) error {
+	if err := ctx.Err(); nil != err {
+		return err
+	}
+	return a.dao.Delete(id)
+}
+
+type NotFoundError struct {
+	ID Identifier
+}
+
+func (e NotFoundError) Error() string {
+	return fmt.Sprintf("Entity identified by '%s' not found", e.ID)
+}
+
+func (e NotFoundError) ErrorKind() ErrorKind {
+	return KindNotFound
+}
+
+func (e NotFoundError) ErrorDetail() string {
+	return e.Error()
+}
+
+func IsNotFound(err error) bool {
+	_, ok := errors.Cause(err).(NotFoundError)
+	return ok
+}
+
+// VersionMismatchError is returned when storing a VersionedEntity whose version isn't the stored one.
+type VersionMismatchError struct {
+	ID      Identifier
+	Version string
+}
+
+func (e VersionMismatchError) Error() string {
+	return fmt.Sprintf("Entity identified by '%s' is not at version '%s' anymore", e.ID, e.Version)
+}
+
+func (e VersionMismatchError) ErrorKind() ErrorKind {
+	return KindPreconditionFailed
+}
+
+func (e VersionMismatchError) ErrorDetail() string {
+	return e.Error()
+}
+
+func IsVersionMismatch(err error) bool {
+	_, ok := errors.Cause(err).(VersionMismatchError)
+	return ok
+}
+
 type IdentifiableEntity interface {
 	Entity
 	ID() Identifier
 	WithID(Identifier) (IdentifiableEntity, error)
 }
 
+// hasID reports whether the entity carries an identifier. A nil or empty ID is considered absent, and DAOs generate one.
+func hasID(entity IdentifiableEntity) bool {
+	id := entity.ID()
+	return nil != id && "" != id.String()
+}
+
 type Mapper interface {
 	ToEntities(*sql.Rows) ([]Entity, error)
 	ToIdentifiers(*sql.Rows) ([]Identifier, error)
@@ -56,6 +171,33 @@ type Queries interface {
 	Delete() string
 }
 
+// UpsertQueries can be implemented by Queries when the dialect supports an atomic insert-or-update.
+// Upsert receives the same arguments as Insert.
+type UpsertQueries interface {
+	Upsert() string
+}
+
+// FilterableQueries can be implemented by Queries to let DatabaseDAO implement FilterableDAO.
+// Each method returns the statement and its arguments.
+type FilterableQueries interface {
+	GetAllEntitiesMatching(Query) (string, []interface{}, error)
+	GetAllIDsMatching(Query) (string, []interface{}, error)
+	TotalNumberOfEntitiesMatching(Filter) (string, []interface{}, error)
+}
+
+// BatchQueries can be implemented by Queries to let DatabaseDAO load several entities in one statement.
+// GetBatch receives the number of identifiers to select.
+type BatchQueries interface {
+	GetBatch(nbIDs int) string
+}
+
+// VersionedQueries can be implemented by Queries to let DatabaseDAO store VersionedEntity with a single compare-and-set statement.
+// UpdateVersioned receives the same arguments as Update, the version being the expected one, and increments the stored version.
+// An empty statement disables it.
+type VersionedQueries interface {
+	UpdateVersioned() string
+}
+
 type queryKey string
 
 const (
@@ -65,15 +207,77 @@ const (
 	get            = queryKey("get")
 	insert         = queryKey("insert")
 	update         = queryKey("update")
-	delete         = queryKey("delete")
+	remove         = queryKey("delete")
+	upsert         = queryKey("upsert")
+	updateVersion  = queryKey("updateVersioned")
 )
 
+// sqlStateError is implemented by driver errors exposing their SQLSTATE code, like those of pgx and lib/pq.
+type sqlStateError interface {
+	SQLState() string
+}
+
+// mysqlConstraintErrors are the MySQL error numbers of integrity constraint violations, prefixing go-sql-driver messages.
+var mysqlConstraintErrors = []string{"Error 1048", "Error 1062", "Error 1216", "Error 1217", "Error 1451", "Error 1452"}
+
+// IsConstraintViolation recognizes integrity constraint violations: SQLSTATE class 23 when the driver error exposes it,
+// SQLite "constraint failed" errors and MySQL constraint error numbers otherwise.
+// Other failures, like serialization failures which are worth retrying, are not recognized.
+func IsConstraintViolation(err error) bool {
+	var stateErr sqlStateError
+	if errors.As(err, &stateErr) {
+		return strings.HasPrefix(stateErr.SQLState(), "23")
+	}
+	msg := err.Error()
+	if strings.Contains(msg, "constraint failed") {
+		return true
+	}
+	for _, prefix := range mysqlConstraintErrors {
+		if strings.HasPrefix(msg, prefix+":") || strings.HasPrefix(msg, prefix+" (") {
+			return true
+		}
+	}
+	return false
+}
+
+// mysqlSerializationErrors are the MySQL error numbers of deadlocks, prefixing go-sql-driver messages.
+var mysqlSerializationErrors = []string{"Error 1213"}
+
+// IsSerializationFailure recognizes failures of transactions conflicting with concurrent ones: SQLSTATE class 40 when the
+// driver error exposes it, SQLite busy or locked errors and MySQL deadlocks otherwise. Such transactions can be run again.
+func IsSerializationFailure(err error) bool {
+	var stateErr sqlStateError
+	if errors.As(err, &stateErr) {
+		return strings.HasPrefix(stateErr.SQLState(), "40")
+	}
+	msg := errors.Cause(err).Error()
+	if strings.Contains(msg, "database is locked") || strings.Contains(msg, "database table is locked") {
+		return true
+	}
+	for _, prefix := range mysqlSerializationErrors {
+		if strings.HasPrefix(msg, prefix+":") || strings.HasPrefix(msg, prefix+" (") {
+			return true
+		}
+	}
+	return false
+}
+
 type DatabaseDAO struct {
-	idGenerator IdentifierGenerator
-	mapper      Mapper
-	queries     map[queryKey]*sql.Stmt
+	db                   *sql.DB
+	txOptions            *sql.TxOptions
+	idGenerator          IdentifierGenerator
+	mapper               Mapper
+	queries              map[queryKey]*sql.Stmt
+	filterQueries        FilterableQueries
+	batchQueries         BatchQueries
+	constraintViolation  func(error) bool
+	serializationFailure func(error) bool
+	maxBatchSize         int
 }
 
+// defaultMaxBatchSize keeps batches under the bound parameters limits of databases, like the 999 of older SQLite versions.
+const defaultMaxBatchSize = 500
+
 func NewDatabaseDAO(db *sql.DB, mapper Mapper, queries Queries, idGenerator IdentifierGenerator) (*DatabaseDAO, error) {
 	var err error
 	preparedQueries := make(map[queryKey]*sql.Stmt)
@@ -101,26 +305,116 @@ func NewDatabaseDAO(db *sql.DB, mapper Mapper, queries Queries, idGenerator Iden
 	if err != nil {
 		return nil, errors.Wrapf(err, "Error when preparing %s", queries.Update())
 	}
-	preparedQueries[delete], err = db.Prepare(queries.Delete())
+	preparedQueries[remove], err = db.Prepare(queries.Delete())
 	if err != nil {
 		return nil, errors.Wrapf(err, "Error when preparing %s", queries.Delete())
 	}
+	if upsertQueries, ok := queries.(UpsertQueries); ok {
+		preparedQueries[upsert], err = db.Prepare(upsertQueries.Upsert())
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error when preparing %s", upsertQueries.Upsert())
+		}
+	}
+
+	if versionedQueries, ok := queries.(VersionedQueries); ok && "" != versionedQueries.UpdateVersioned() {
+		preparedQueries[updateVersion], err = db.Prepare(versionedQueries.UpdateVersioned())
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error when preparing %s", versionedQueries.UpdateVersioned())
+		}
+	}
+
+	filterQueries, _ := queries.(FilterableQueries)
+	batchQueries, _ := queries.(BatchQueries)
 
 	return &DatabaseDAO{
-		mapper:      mapper,
-		queries:     preparedQueries,
-		idGenerator: idGenerator,
+		db:            db,
+		filterQueries: filterQueries,
+		batchQueries:  batchQueries,
+		txOptions:     &sql.TxOptions{Isolation: sql.LevelSerializable},
+		mapper:        mapper,
+		queries:       preparedQueries,
+		idGenerator:   idGenerator,
+		maxBatchSize:  defaultMaxBatchSize,
+
+		serializationFailure: IsSerializationFailure,
 	}, nil
 }
 
+func (d *DatabaseDAO) SetTransactionOptions(options *sql.TxOptions) {
+	d.txOptions = options
+}
+
+// SetMaxBatchSize bounds the number of identifiers selected by each GetBatch statement.
+func (d *DatabaseDAO) SetMaxBatchSize(size int) {
+	if size > 0 {
+		d.maxBatchSize = size
+	}
+}
+
+// SetSerializationFailureDetector replaces IsSerializationFailure to recognize the failed transactions to run again.
+// A nil detector disables retries.
+func (d *DatabaseDAO) SetSerializationFailureDetector(detector func(error) bool) {
+	d.serializationFailure = detector
+}
+
+func (d *DatabaseDAO) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return runInTransaction(ctx, d.db, d.txOptions, d.serializationFailure, fn)
+}
+
+func (d *DatabaseDAO) stmt(ctx context.Context, key queryKey) *sql.Stmt {
+	if tx, ok := transactionFrom(ctx, d.db); ok {
+		return tx.StmtContext(ctx, d.queries[key])
+	}
+	return d.queries[key]
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func (d *DatabaseDAO) queryer(ctx context.Context) queryer {
+	if tx, ok := transactionFrom(ctx, d.db); ok {
+		return tx
+	}
+	return d.db
+}
+
+// SetConstraintViolationDetector enables reporting the errors recognized by detector, e.g. IsConstraintViolation, as conflicts.
+// Without detector, database errors are internal errors.
+func (d *DatabaseDAO) SetConstraintViolationDetector(detector func(error) bool) {
+	d.constraintViolation = detector
+}
+
+func (d *DatabaseDAO) wrapExecError(err error, format string, args ...interface{}) error {
+	if nil != d.constraintViolation && d.constraintViolation(err) {
+		return NewError(KindConflict, err, "Operation violates a constraint")
+	}
+	return errors.Wrapf(err, format, args...)
+}
+
+// Close releases the prepared statements. The database itself is left open.
 func (d *DatabaseDAO) Close() {
-	for _, query := range d.queries {
-		query.Close()
+	d.CloseStatements()
+}
+
+// CloseStatements releases the prepared statements like Close, and returns the first error met.
+func (d *DatabaseDAO) CloseStatements() error {
+	var firstErr error
+	for key, query := range d.queries {
+		if err := query.Close(); nil != err && nil == firstErr {
+			firstErr = errors.Wrapf(err, "Closing %s statement", key)
+		}
 	}
+	return firstErr
 }
 
 func (d *DatabaseDAO) GetAllEntities(p Pagination) ([]Entity, error) {
-	rows, err := d.queries[getAllEntities].Query(p.Offset(), p.Limit())
+	return d.GetAllEntitiesContext(context.Background(), p)
+}
+
+func (d *DatabaseDAO) GetAllEntitiesContext(ctx context.Context, p Pagination) ([]Entity, error) {
+	rows, err := d.stmt(ctx, getAllEntities).QueryContext(ctx, p.Offset(), p.Limit())
 	if err != nil {
 		return nil, errors.Wrapf(err, "Retrieving entities from database")
 	}
@@ -136,9 +430,11 @@ func (d *DatabaseDAO) GetAllEntities(p Pagination) ([]Entity, error) {
 }
 
 func (d *DatabaseDAO) GetAllIDs(p Pagination) ([]Identifier, error) {
-	queries := d.queries
-	getAllQuery := queries[getAllIDs]
-	rows, err := getAllQuery.Query(p.Offset(), p.Limit())
+	return d.GetAllIDsContext(context.Background(), p)
+}
+
+func (d *DatabaseDAO) GetAllIDsContext(ctx context.Context, p Pagination) ([]Identifier, error) {
+	rows, err := d.stmt(ctx, getAllIDs).QueryContext(ctx, p.Offset(), p.Limit())
 	if err != nil {
 		return nil, errors.Wrapf(err, "Retrieving entities from database")
 	}
@@ -153,8 +449,83 @@ func (d *DatabaseDAO) GetAllIDs(p Pagination) ([]Identifier, error) {
 	return identifiers, nil
 }
 
+func (d *DatabaseDAO) GetAllEntitiesMatching(ctx context.Context, query Query) ([]Entity, error) {
+	if nil == d.filterQueries {
+		return nil, fmt.Errorf("Queries don't support filtering")
+	}
+	statement, args, err := d.filterQueries.GetAllEntitiesMatching(query)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Generating filtered query")
+	}
+	rows, err := d.queryer(ctx).QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Retrieving filtered entities from database")
+	}
+	defer rows.Close()
+	entities, err := d.mapper.ToEntities(rows)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Map result set to entity")
+	}
+	if err = rows.Err(); nil != err {
+		return nil, errors.Wrapf(err, "Error while looping through entity rows")
+	}
+	if nil != query.Keyset && query.Keyset.Backward {
+		for i, j := 0, len(entities)-1; i < j; i, j = i+1, j-1 {
+			entities[i], entities[j] = entities[j], entities[i]
+		}
+	}
+	return entities, nil
+}
+
+func (d *DatabaseDAO) GetAllIDsMatching(ctx context.Context, query Query) ([]Identifier, error) {
+	if nil == d.filterQueries {
+		return nil, fmt.Errorf("Queries don't support filtering")
+	}
+	statement, args, err := d.filterQueries.GetAllIDsMatching(query)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Generating filtered query")
+	}
+	rows, err := d.queryer(ctx).QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Retrieving filtered identifiers from database")
+	}
+	defer rows.Close()
+	identifiers, err := d.mapper.ToIdentifiers(rows)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Map result set to identifiers")
+	}
+	if err = rows.Err(); nil != err {
+		return nil, errors.Wrapf(err, "Error while looping through identifiers rows")
+	}
+	if nil != query.Keyset && query.Keyset.Backward {
+		for i, j := 0, len(identifiers)-1; i < j; i, j = i+1, j-1 {
+			identifiers[i], identifiers[j] = identifiers[j], identifiers[i]
+		}
+	}
+	return identifiers, nil
+}
+
+func (d *DatabaseDAO) TotalNumberOfEntitiesMatching(ctx context.Context, filter Filter) (int64, error) {
+	if nil == d.filterQueries {
+		return 0, fmt.Errorf("Queries don't support filtering")
+	}
+	statement, args, err := d.filterQueries.TotalNumberOfEntitiesMatching(filter)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Generating filtered query")
+	}
+	var nbItems int64
+	if err = d.queryer(ctx).QueryRowContext(ctx, statement, args...).Scan(&nbItems); nil != err {
+		return 0, errors.Wrapf(err, "Counting number of filtered entities in database")
+	}
+	return nbItems, nil
+}
+
 func (d *DatabaseDAO) TotalNumberOfEntities() (int64, error) {
-	row := d.queries[size].QueryRow()
+	return d.TotalNumberOfEntitiesContext(context.Background())
+}
+
+func (d *DatabaseDAO) TotalNumberOfEntitiesContext(ctx context.Context) (int64, error) {
+	row := d.stmt(ctx, size).QueryRowContext(ctx)
 	var nbItems int64
 	err := row.Scan(&nbItems)
 	if err != nil {
@@ -164,7 +535,11 @@ func (d *DatabaseDAO) TotalNumberOfEntities() (int64, error) {
 }
 
 func (d *DatabaseDAO) Get(id Identifier) (Entity, error) {
-	rows, err := d.queries[get].Query(id)
+	return d.GetContext(context.Background(), id)
+}
+
+func (d *DatabaseDAO) GetContext(ctx context.Context, id Identifier) (Entity, error) {
+	rows, err := d.stmt(ctx, get).QueryContext(ctx, id)
 	if err != nil {
 		return nil, errors.Wrapf(err, "Retrieving entities from database")
 	}
@@ -181,15 +556,72 @@ func (d *DatabaseDAO) Get(id Identifier) (Entity, error) {
 		return nil, fmt.Errorf("Expected only one entity identified by '%s' but got %d", id, nbEntities)
 	}
 	if nbEntities == 0 {
-		return nil, nil
+		return nil, NotFoundError{ID: id}
 	}
 	return entities[0], nil
 }
 
+// GetBatch loads the entities in one statement per batch of identifiers when Queries implement BatchQueries, one by one otherwise.
+func (d *DatabaseDAO) GetBatch(ctx context.Context, ids []Identifier) (map[string]Entity, error) {
+	entities := make(map[string]Entity)
+	if nil == d.batchQueries {
+		for _, id := range ids {
+			entity, err := d.GetContext(ctx, id)
+			if IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			entities[id.String()] = entity
+		}
+		return entities, nil
+	}
+	for start := 0; start < len(ids); start += d.maxBatchSize {
+		end := start + d.maxBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		if err := d.loadBatch(ctx, ids[start:end], entities); nil != err {
+			return nil, err
+		}
+	}
+	return entities, nil
+}
+
+func (d *DatabaseDAO) loadBatch(ctx context.Context, ids []Identifier, entities map[string]Entity) error {
+	args := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	rows, err := d.queryer(ctx).QueryContext(ctx, d.batchQueries.GetBatch(len(ids)), args...)
+	if err != nil {
+		return errors.Wrapf(err, "Retrieving entities from database")
+	}
+	defer rows.Close()
+	loaded, err := d.mapper.ToEntities(rows)
+	if err != nil {
+		return errors.Wrapf(err, "Map result set to entity")
+	}
+	if err = rows.Err(); nil != err {
+		return errors.Wrapf(err, "Error while looping through entity rows")
+	}
+	for _, entity := range loaded {
+		identifiable, ok := entity.(IdentifiableEntity)
+		if !ok {
+			return fmt.Errorf("Entity '%+v' must implement IdentifiableEntity to be loaded in batch", entity)
+		}
+		entities[identifiable.ID().String()] = entity
+	}
+	return nil
+}
+
 func (d *DatabaseDAO) Set(entity IdentifiableEntity) (Identifier, error) {
-	id := entity.ID()
-	shouldInsert := false
-	if nil == id {
+	return d.SetContext(context.Background(), entity)
+}
+
+func (d *DatabaseDAO) SetContext(ctx context.Context, entity IdentifiableEntity) (Identifier, error) {
+	if !hasID(entity) {
 		generator := d.idGenerator
 		id, err := generator.Generate(entity)
 		if err != nil {
@@ -199,38 +631,119 @@ func (d *DatabaseDAO) Set(entity IdentifiableEntity) (Identifier, error) {
 		if err != nil {
 			return nil, errors.Wrapf(err, "Setting ID")
 		}
-		shouldInsert = true
-	} else {
-		found, err := d.Get(id)
-		if err != nil {
-			return nil, errors.Wrapf(err, "Checking if entity exist")
+		if entity, err = withVersion(entity, initialVersion); nil != err {
+			return nil, err
+		}
+		if _, err = d.exec(ctx, insert, entity); err != nil {
+			return nil, err
 		}
-		shouldInsert = nil == found
+		return entity.ID(), nil
 	}
 
-	s, err := d.mapper.ToSlice(entity)
+	if versioned, ok := entity.(VersionedEntity); ok {
+		if err := d.InTransaction(ctx, func(ctx context.Context) error {
+			return d.setVersioned(ctx, versioned)
+		}); nil != err {
+			return nil, err
+		}
+		return entity.ID(), nil
+	}
+
+	if _, ok := d.queries[upsert]; ok {
+		if _, err := d.exec(ctx, upsert, entity); err != nil {
+			return nil, err
+		}
+		return entity.ID(), nil
+	}
+
+	err := d.InTransaction(ctx, func(ctx context.Context) error {
+		_, err := d.GetContext(ctx, entity.ID())
+		if err != nil && !IsNotFound(err) {
+			return errors.Wrapf(err, "Checking if entity exist")
+		}
+		if IsNotFound(err) {
+			_, err = d.exec(ctx, insert, entity)
+			return err
+		}
+		_, err = d.exec(ctx, update, entity)
+		return err
+	})
 	if err != nil {
-		return nil, errors.Wrapf(err, "Turn an entity into a slice of fields")
+		return nil, err
 	}
-	if shouldInsert {
-		_, err := d.queries[insert].Exec(s...)
+	return entity.ID(), nil
+}
+
+// setVersioned updates the entity if it is still at its version, or inserts it at the initial version.
+func (d *DatabaseDAO) setVersioned(ctx context.Context, entity VersionedEntity) error {
+	if _, ok := d.queries[updateVersion]; ok {
+		result, err := d.exec(ctx, updateVersion, entity)
 		if err != nil {
-			return nil, errors.Wrapf(err, "Inserting '%+v'", entity)
+			return err
 		}
-	} else {
-		_, err := d.queries[update].Exec(s...)
+		nbUpdated, err := result.RowsAffected()
+		if err != nil {
+			return errors.Wrapf(err, "Counting updated rows")
+		}
+		if nbUpdated > 0 {
+			return nil
+		}
+	}
+	current, err := d.GetContext(ctx, entity.ID())
+	if IsNotFound(err) {
+		created, err := withVersion(entity, initialVersion)
 		if err != nil {
-			return nil, errors.Wrapf(err, "Updating '%+v'", entity)
+			return err
 		}
+		_, err = d.exec(ctx, insert, created)
+		return err
+	}
+	if err != nil {
+		return errors.Wrapf(err, "Checking version of '%s'", entity.ID())
+	}
+	if versioned, ok := current.(Versioned); !ok || versioned.Version() != entity.Version() {
+		return VersionMismatchError{ID: entity.ID(), Version: entity.Version()}
+	}
+	// Without versioned update statement, the transaction protects the version check
+	next, err := nextVersion(entity.Version())
+	if err != nil {
+		return err
+	}
+	updated, err := entity.WithVersion(next)
+	if err != nil {
+		return errors.Wrapf(err, "Setting version '%s'", next)
 	}
+	_, err = d.exec(ctx, update, updated)
+	return err
+}
 
-	return entity.ID(), nil
+func (d *DatabaseDAO) exec(ctx context.Context, key queryKey, entity IdentifiableEntity) (sql.Result, error) {
+	s, err := d.mapper.ToSlice(entity)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Turn an entity into a slice of fields")
+	}
+	result, err := d.stmt(ctx, key).ExecContext(ctx, s...)
+	if err != nil {
+		return nil, d.wrapExecError(err, "Executing %s of '%+v'", key, entity)
+	}
+	return result, nil
 }
 
 func (d *DatabaseDAO) Delete(id Identifier) error {
-	_, err := d.queries[delete].Exec(id)
+	return d.DeleteContext(context.Background(), id)
+}
+
+func (d *DatabaseDAO) DeleteContext(ctx context.Context, id Identifier) error {
+	result, err := d.stmt(ctx, remove).ExecContext(ctx, id)
 	if err != nil {
-		return errors.Wrapf(err, "Deleting '%s'", id.String())
+		return d.wrapExecError(err, "Deleting '%s'", id.String())
+	}
+	nbDeleted, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "Counting deleted rows for '%s'", id.String())
+	}
+	if nbDeleted == 0 {
+		return NotFoundError{ID: id}
 	}
 	return nil
 }
@@ -0,0 +1,244 @@
+package rest_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/normegil/rest"
+	pkgErrors "github.com/pkg/errors"
+)
+
+func TestDatabaseDAODeleteUnknownID(t *testing.T) {
+	mapper, err := rest.NewStructMapper(sqlEntity{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dao := newSQLiteDAO(t, mapper, rest.NewMapperQueries(rest.SQLite, "things", mapper))
+	if _, err = dao.Set(sqlEntity{Identifier: "existing", Name: "name"}); nil != err {
+		t.Fatal(err)
+	}
+	err = dao.Delete(rest.StringIdentifier("unknown"))
+	if notFound, ok := err.(rest.NotFoundError); !ok || "unknown" != notFound.ID.String() {
+		t.Errorf("Expected a not found error for 'unknown' but got %+v", err)
+	}
+	if nb, err := dao.TotalNumberOfEntities(); nil != err || 1 != nb {
+		t.Errorf("Deleting an unknown ID changed the number of entities: %d (%+v)", nb, err)
+	}
+}
+
+// rawSQLEntity exposes its ID even when empty.
+type rawSQLEntity sqlEntity
+
+func (e rawSQLEntity) ID() rest.Identifier {
+	return rest.StringIdentifier(e.Identifier)
+}
+
+func (e rawSQLEntity) WithID(id rest.Identifier) (rest.IdentifiableEntity, error) {
+	e.Identifier = id.String()
+	return e, nil
+}
+
+func TestDatabaseDAOSetEmptyID(t *testing.T) {
+	mapper, err := rest.NewStructMapper(rawSQLEntity{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dao := newSQLiteDAO(t, mapper, rest.NewMapperQueries(rest.SQLite, "things", mapper))
+	id, err := dao.Set(rawSQLEntity{Name: "name"})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "" == id.String() {
+		t.Fatal("No ID was generated for an entity with an empty ID")
+	}
+	if _, err = dao.Get(id); nil != err {
+		t.Errorf("Entity with generated ID '%s' cannot be loaded: %+v", id, err)
+	}
+}
+
+type sqlStateError string
+
+func (e sqlStateError) Error() string {
+	return "database error " + string(e)
+}
+
+func (e sqlStateError) SQLState() string {
+	return string(e)
+}
+
+func TestIsConstraintViolation(t *testing.T) {
+	testcases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"SQLite unique", errors.New("UNIQUE constraint failed: things.id"), true},
+		{"SQLite not null", errors.New("NOT NULL constraint failed: things.name"), true},
+		{"MySQL duplicate", errors.New("Error 1062: Duplicate entry '1' for key 'PRIMARY'"), true},
+		{"MySQL foreign key with state", errors.New("Error 1452 (23000): Cannot add or update a child row"), true},
+		{"SQLSTATE unique violation", sqlStateError("23505"), true},
+		{"Wrapped SQLSTATE", fmt.Errorf("inserting: %w", sqlStateError("23503")), true},
+		{"SQLSTATE serialization failure", sqlStateError("40001"), false},
+		{"PostgreSQL serialization failure", errors.New("pq: could not serialize access due to concurrent update"), false},
+		{"MySQL deadlock", errors.New("Error 1213 (40001): Deadlock found when trying to get lock"), false},
+		{"Unrelated mention", errors.New("dial tcp: connection refused while checking duplicate constraint names"), false},
+	}
+	for _, testdata := range testcases {
+		t.Run(testdata.name, func(t *testing.T) {
+			if testdata.expected != rest.IsConstraintViolation(testdata.err) {
+				t.Errorf("Classification of '%s' doesn't meet the expected result (%t)", testdata.err, testdata.expected)
+			}
+		})
+	}
+}
+
+func TestIsSerializationFailure(t *testing.T) {
+	testcases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"SQLSTATE serialization failure", sqlStateError("40001"), true},
+		{"SQLSTATE deadlock", pkgErrors.Wrap(sqlStateError("40P01"), "Committing transaction"), true},
+		{"SQLite busy", pkgErrors.Wrap(errors.New("database is locked"), "Inserting"), true},
+		{"MySQL deadlock", errors.New("Error 1213 (40001): Deadlock found when trying to get lock"), true},
+		{"SQLSTATE unique violation", sqlStateError("23505"), false},
+		{"SQLite unique", errors.New("UNIQUE constraint failed: things.id"), false},
+		{"MySQL duplicate", errors.New("Error 1062: Duplicate entry '1' for key 'PRIMARY'"), false},
+	}
+	for _, testdata := range testcases {
+		t.Run(testdata.name, func(t *testing.T) {
+			if testdata.expected != rest.IsSerializationFailure(testdata.err) {
+				t.Errorf("Classification of '%s' doesn't meet the expected result (%t)", testdata.err, testdata.expected)
+			}
+		})
+	}
+}
+
+func TestDatabaseDAOConstraintViolationDetector(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if _, err = db.Exec(`CREATE TABLE things (id TEXT PRIMARY KEY, name TEXT NOT NULL UNIQUE, size INTEGER NOT NULL)`); nil != err {
+		t.Fatal(err)
+	}
+	mapper, err := rest.NewStructMapper(sqlEntity{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dao, err := rest.NewDatabaseDAO(db, mapper, rest.NewMapperQueries(rest.SQLite, "things", mapper), rest.UUIDIdentifierGenerator{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dao.Close()
+	if _, err = dao.Set(sqlEntity{Name: "duplicated"}); nil != err {
+		t.Fatal(err)
+	}
+
+	if _, err = dao.Set(sqlEntity{Name: "duplicated"}); rest.KindInternal != rest.ErrorKindOf(err) {
+		t.Errorf("Violation without detector returned kind %s instead of %s: %+v", rest.ErrorKindOf(err), rest.KindInternal, err)
+	}
+	dao.SetConstraintViolationDetector(rest.IsConstraintViolation)
+	if _, err = dao.Set(sqlEntity{Name: "duplicated"}); rest.KindConflict != rest.ErrorKindOf(err) {
+		t.Errorf("Violation with detector returned kind %s instead of %s: %+v", rest.ErrorKindOf(err), rest.KindConflict, err)
+	}
+}
+
+// rendezvousMapper holds each write until another one is about to happen, or a delay expires.
+// Writes whose existence check isn't isolated by a transaction then both see the entity missing.
+type rendezvousMapper struct {
+	rest.Mapper
+	arrived chan struct{}
+}
+
+func (m rendezvousMapper) ToSlice(entity rest.Entity) ([]interface{}, error) {
+	select {
+	case m.arrived <- struct{}{}:
+	case <-m.arrived:
+	case <-time.After(100 * time.Millisecond):
+	}
+	return m.Mapper.ToSlice(entity)
+}
+
+func TestDatabaseDAOConcurrentSetOfNewID(t *testing.T) {
+	structMapper, err := rest.NewStructMapper(sqlEntity{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapper := rendezvousMapper{Mapper: structMapper, arrived: make(chan struct{})}
+	testcases := []struct {
+		name    string
+		queries rest.Queries
+	}{
+		{"Upsert", rest.NewMapperQueries(rest.SQLite, "things", structMapper)},
+		{"Transaction", transactionalQueries{rest.NewMapperQueries(rest.SQLite, "things", structMapper)}},
+	}
+	for _, testdata := range testcases {
+		t.Run(testdata.name, func(t *testing.T) {
+			dao := newSQLiteFileDAO(t, mapper, testdata.queries)
+			var wg sync.WaitGroup
+			for i := 0; i < 2; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					// A second insert of the same ID would fail on the primary key
+					if _, err := dao.Set(sqlEntity{Identifier: "new", Name: fmt.Sprintf("set-%d", i), Size: int64(i)}); nil != err {
+						t.Errorf("Set %d failed: %+v", i, err)
+					}
+				}(i)
+			}
+			wg.Wait()
+			if nb, err := dao.TotalNumberOfEntities(); nil != err || 1 != nb {
+				t.Errorf("Concurrent sets of the same ID stored %d entities (%+v)", nb, err)
+			}
+		})
+	}
+}
+
+// batchRecordingQueries records the number of identifiers selected by each batch.
+type batchRecordingQueries struct {
+	*rest.GeneratedQueries
+	batches []int
+}
+
+func (q *batchRecordingQueries) GetBatch(nbIDs int) string {
+	q.batches = append(q.batches, nbIDs)
+	return q.GeneratedQueries.GetBatch(nbIDs)
+}
+
+func TestDatabaseDAOGetBatchSize(t *testing.T) {
+	mapper, err := rest.NewStructMapper(sqlEntity{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	queries := &batchRecordingQueries{GeneratedQueries: rest.NewMapperQueries(rest.SQLite, "things", mapper)}
+	dao := newSQLiteDAO(t, mapper, queries)
+	dao.SetMaxBatchSize(2)
+	ids := make([]rest.Identifier, 0)
+	for i := 0; i < 5; i++ {
+		id, err := dao.Set(sqlEntity{Name: fmt.Sprintf("entity-%d", i)})
+		if nil != err {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	entities, err := dao.GetBatch(context.Background(), ids)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if len(ids) != len(entities) {
+		t.Errorf("Loaded entities (%d) don't meet the expected result (%d)", len(entities), len(ids))
+	}
+	if !reflect.DeepEqual([]int{2, 2, 1}, queries.batches) {
+		t.Errorf("Batches (%v) don't meet the expected result (%v)", queries.batches, []int{2, 2, 1})
+	}
+}
@@ -0,0 +1,384 @@
+package rest
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Dialect describes the SQL syntax differences between databases.
+type Dialect interface {
+	// Placeholder returns the parameter marker for the 1-based position.
+	Placeholder(position int) string
+	Quote(identifier string) string
+	// Paginate returns the clause limiting a result set, with offset and limit bound at the given positions.
+	Paginate(offsetPosition int, limitPosition int) string
+	// Upsert returns the suffix turning an insert into an insert-or-update.
+	Upsert(keyColumn string, columns []string) string
+}
+
+var (
+	SQLite     Dialect = sqliteDialect{}
+	PostgreSQL Dialect = postgreSQLDialect{}
+	MySQL      Dialect = mySQLDialect{}
+)
+
+type sqliteDialect struct{}
+
+func (d sqliteDialect) Placeholder(position int) string {
+	return "?" + strconv.Itoa(position)
+}
+
+func (d sqliteDialect) Quote(identifier string) string {
+	return quote(identifier, `"`)
+}
+
+func (d sqliteDialect) Paginate(offsetPosition int, limitPosition int) string {
+	return "LIMIT " + d.Placeholder(limitPosition) + " OFFSET " + d.Placeholder(offsetPosition)
+}
+
+func (d sqliteDialect) Upsert(keyColumn string, columns []string) string {
+	return onConflictUpsert(d, keyColumn, columns)
+}
+
+type postgreSQLDialect struct{}
+
+func (d postgreSQLDialect) Placeholder(position int) string {
+	return "$" + strconv.Itoa(position)
+}
+
+func (d postgreSQLDialect) Quote(identifier string) string {
+	return quote(identifier, `"`)
+}
+
+func (d postgreSQLDialect) Paginate(offsetPosition int, limitPosition int) string {
+	return "LIMIT " + d.Placeholder(limitPosition) + " OFFSET " + d.Placeholder(offsetPosition)
+}
+
+func (d postgreSQLDialect) Upsert(keyColumn string, columns []string) string {
+	return onConflictUpsert(d, keyColumn, columns)
+}
+
+type mySQLDialect struct{}
+
+func (d mySQLDialect) Placeholder(_ int) string {
+	return "?"
+}
+
+func (d mySQLDialect) Quote(identifier string) string {
+	return quote(identifier, "`")
+}
+
+// Paginate relies on MySQL "LIMIT offset, count" syntax as markers cannot be reordered: the offset has to be bound first.
+func (d mySQLDialect) Paginate(_ int, _ int) string {
+	return "LIMIT ?, ?"
+}
+
+// Upsert refers to the inserted row through an alias, which needs MySQL 8.0.19, as VALUES() is deprecated since 8.0.20.
+func (d mySQLDialect) Upsert(keyColumn string, columns []string) string {
+	assignments := make([]string, 0, len(columns))
+	for _, column := range columns {
+		if column != keyColumn {
+			assignments = append(assignments, d.Quote(column)+" = new."+d.Quote(column))
+		}
+	}
+	if 0 == len(assignments) {
+		assignments = append(assignments, d.Quote(keyColumn)+" = "+d.Quote(keyColumn))
+	}
+	return "AS new ON DUPLICATE KEY UPDATE " + strings.Join(assignments, ", ")
+}
+
+func onConflictUpsert(d Dialect, keyColumn string, columns []string) string {
+	assignments := make([]string, 0, len(columns))
+	for _, column := range columns {
+		if column != keyColumn {
+			assignments = append(assignments, d.Quote(column)+" = excluded."+d.Quote(column))
+		}
+	}
+	if 0 == len(assignments) {
+		return "ON CONFLICT (" + d.Quote(keyColumn) + ") DO NOTHING"
+	}
+	return "ON CONFLICT (" + d.Quote(keyColumn) + ") DO UPDATE SET " + strings.Join(assignments, ", ")
+}
+
+func quote(identifier string, mark string) string {
+	parts := strings.Split(identifier, ".")
+	for i, part := range parts {
+		parts[i] = mark + strings.Replace(part, mark, mark+mark, -1) + mark
+	}
+	return strings.Join(parts, ".")
+}
+
+// GeneratedQueries implements Queries, UpsertQueries, FilterableQueries, BatchQueries and VersionedQueries for a single table.
+// Insert, Update and Upsert expect the columns, then the version column if any, followed by the primary key, as produced by StructMapper.ToSlice.
+// GetAllEntities and GetAllIDs expect the offset followed by the limit, as DatabaseDAO passes them.
+type GeneratedQueries struct {
+	dialect       Dialect
+	table         string
+	keyColumn     string
+	versionColumn string
+	columns       []string
+	properties    map[string]string
+}
+
+func NewQueries(dialect Dialect, table string, keyColumn string, columns []string) *GeneratedQueries {
+	return &GeneratedQueries{
+		dialect:   dialect,
+		table:     table,
+		keyColumn: keyColumn,
+		columns:   orderColumns(columns, keyColumn, ""),
+	}
+}
+
+// NewMapperQueries generates the queries matching the columns of mapper.
+func NewMapperQueries(dialect Dialect, table string, mapper *StructMapper) *GeneratedQueries {
+	queries := NewQueries(dialect, table, mapper.KeyColumn(), mapper.Columns())
+	if "" != mapper.VersionColumn() {
+		queries.SetVersionColumn(mapper.VersionColumn())
+	}
+	queries.SetPropertyColumns(mapper.PropertyColumns())
+	return queries
+}
+
+// SetPropertyColumns makes filters and sorts name the JSON properties of entities, like MemoryDAO does, instead of columns.
+// Properties are translated to their column, others are unknown.
+func (q *GeneratedQueries) SetPropertyColumns(properties map[string]string) {
+	q.properties = properties
+}
+
+// SetVersionColumn enables UpdateVersioned. The version column moves right before the primary key in the expected arguments.
+func (q *GeneratedQueries) SetVersionColumn(column string) {
+	q.versionColumn = column
+	q.columns = orderColumns(q.columns, q.keyColumn, column)
+}
+
+// orderColumns puts the version column, when not empty, then the key column at the end of columns.
+func orderColumns(columns []string, keyColumn string, versionColumn string) []string {
+	ordered := make([]string, 0, len(columns)+2)
+	for _, column := range columns {
+		if column != keyColumn && column != versionColumn {
+			ordered = append(ordered, column)
+		}
+	}
+	if "" != versionColumn {
+		ordered = append(ordered, versionColumn)
+	}
+	return append(ordered, keyColumn)
+}
+
+func (q *GeneratedQueries) quotedColumns() string {
+	quoted := make([]string, 0, len(q.columns))
+	for _, column := range q.columns {
+		quoted = append(quoted, q.dialect.Quote(column))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func (q *GeneratedQueries) orderByKey() string {
+	return "ORDER BY " + q.dialect.Quote(q.keyColumn)
+}
+
+func (q *GeneratedQueries) GetAllEntities() string {
+	return "SELECT " + q.quotedColumns() + " FROM " + q.dialect.Quote(q.table) + " " + q.orderByKey() + " " + q.dialect.Paginate(1, 2)
+}
+
+func (q *GeneratedQueries) GetAllIDs() string {
+	return "SELECT " + q.dialect.Quote(q.keyColumn) + " FROM " + q.dialect.Quote(q.table) + " " + q.orderByKey() + " " + q.dialect.Paginate(1, 2)
+}
+
+func (q *GeneratedQueries) TotalNumberOfEntities() string {
+	return "SELECT COUNT(*) FROM " + q.dialect.Quote(q.table)
+}
+
+func (q *GeneratedQueries) Get() string {
+	return "SELECT " + q.quotedColumns() + " FROM " + q.dialect.Quote(q.table) + " WHERE " + q.dialect.Quote(q.keyColumn) + " = " + q.dialect.Placeholder(1)
+}
+
+func (q *GeneratedQueries) GetBatch(nbIDs int) string {
+	placeholders := make([]string, 0, nbIDs)
+	for i := 1; i <= nbIDs; i++ {
+		placeholders = append(placeholders, q.dialect.Placeholder(i))
+	}
+	return "SELECT " + q.quotedColumns() + " FROM " + q.dialect.Quote(q.table) + " WHERE " + q.dialect.Quote(q.keyColumn) + " IN (" + strings.Join(placeholders, ", ") + ")"
+}
+
+func (q *GeneratedQueries) Insert() string {
+	placeholders := make([]string, 0, len(q.columns))
+	for i := range q.columns {
+		placeholders = append(placeholders, q.dialect.Placeholder(i+1))
+	}
+	return "INSERT INTO " + q.dialect.Quote(q.table) + " (" + q.quotedColumns() + ") VALUES (" + strings.Join(placeholders, ", ") + ")"
+}
+
+func (q *GeneratedQueries) Update() string {
+	assignments := make([]string, 0, len(q.columns)-1)
+	for i, column := range q.columns[:len(q.columns)-1] {
+		assignments = append(assignments, q.dialect.Quote(column)+" = "+q.dialect.Placeholder(i+1))
+	}
+	if 0 == len(assignments) {
+		assignments = append(assignments, q.dialect.Quote(q.keyColumn)+" = "+q.dialect.Quote(q.keyColumn))
+	}
+	return "UPDATE " + q.dialect.Quote(q.table) + " SET " + strings.Join(assignments, ", ") + " WHERE " + q.dialect.Quote(q.keyColumn) + " = " + q.dialect.Placeholder(len(q.columns))
+}
+
+// UpdateVersioned updates the entity only if its stored version is the given one, and increments the version.
+// It is empty when no version column is set.
+func (q *GeneratedQueries) UpdateVersioned() string {
+	if "" == q.versionColumn {
+		return ""
+	}
+	version := q.dialect.Quote(q.versionColumn)
+	assignments := make([]string, 0, len(q.columns)-1)
+	for i, column := range q.columns[:len(q.columns)-2] {
+		assignments = append(assignments, q.dialect.Quote(column)+" = "+q.dialect.Placeholder(i+1))
+	}
+	assignments = append(assignments, version+" = "+version+" + 1")
+	return "UPDATE " + q.dialect.Quote(q.table) + " SET " + strings.Join(assignments, ", ") +
+		" WHERE " + version + " = " + q.dialect.Placeholder(len(q.columns)-1) + " AND " + q.dialect.Quote(q.keyColumn) + " = " + q.dialect.Placeholder(len(q.columns))
+}
+
+func (q *GeneratedQueries) Upsert() string {
+	return q.Insert() + " " + q.dialect.Upsert(q.keyColumn, q.columns)
+}
+
+func (q *GeneratedQueries) Delete() string {
+	return "DELETE FROM " + q.dialect.Quote(q.table) + " WHERE " + q.dialect.Quote(q.keyColumn) + " = " + q.dialect.Placeholder(1)
+}
+
+// column returns the column filtered or sorted by field, a property when properties are set, a column otherwise.
+func (q *GeneratedQueries) column(field string) (string, bool) {
+	if nil != q.properties {
+		column, found := q.properties[field]
+		return column, found
+	}
+	for _, known := range q.columns {
+		if known == field {
+			return known, true
+		}
+	}
+	return "", false
+}
+
+// where translates filter into a WHERE clause whose parameters start at position 1.
+func (q *GeneratedQueries) where(filter Filter) (string, []interface{}, error) {
+	if 0 == len(filter) {
+		return "", nil, nil
+	}
+	clauses := make([]string, 0, len(filter))
+	args := make([]interface{}, 0, len(filter))
+	for _, condition := range filter {
+		column, found := q.column(condition.Field)
+		if !found {
+			return "", nil, NewError(KindValidation, nil, "Unknown filter field '%s'", condition.Field)
+		}
+		column = q.dialect.Quote(column)
+		switch condition.Operator {
+		case In:
+			placeholders := make([]string, 0, len(condition.Values))
+			for _, value := range condition.Values {
+				args = append(args, value)
+				placeholders = append(placeholders, q.dialect.Placeholder(len(args)))
+			}
+			clauses = append(clauses, column+" IN ("+strings.Join(placeholders, ", ")+")")
+		case Prefix:
+			args = append(args, escapeLike(condition.Values[0])+"%")
+			clauses = append(clauses, column+" LIKE "+q.dialect.Placeholder(len(args))+" ESCAPE '!'")
+		default:
+			sqlOperator, known := sqlOperators[condition.Operator]
+			if !known {
+				return "", nil, NewError(KindValidation, nil, "Unknown filter operator '%s' on '%s'", condition.Operator, condition.Field)
+			}
+			args = append(args, condition.Values[0])
+			clauses = append(clauses, column+" "+sqlOperator+" "+q.dialect.Placeholder(len(args)))
+		}
+	}
+	return " WHERE " + strings.Join(clauses, " AND "), args, nil
+}
+
+var sqlOperators = map[Operator]string{
+	Equal:          "=",
+	NotEqual:       "<>",
+	LowerThan:      "<",
+	LowerOrEqual:   "<=",
+	GreaterThan:    ">",
+	GreaterOrEqual: ">=",
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(value)
+}
+
+// orderBy always ends with the primary key, so pages stay stable when sorted values are not unique.
+func (q *GeneratedQueries) orderBy(fields []SortField) (string, error) {
+	orders := make([]string, 0, len(fields)+1)
+	for _, field := range fields {
+		column, found := q.column(field.Field)
+		if !found {
+			return "", NewError(KindValidation, nil, "Unknown sort field '%s'", field.Field)
+		}
+		order := q.dialect.Quote(column)
+		if field.Descending {
+			order += " DESC"
+		}
+		orders = append(orders, order)
+	}
+	orders = append(orders, q.dialect.Quote(q.keyColumn))
+	return "ORDER BY " + strings.Join(orders, ", "), nil
+}
+
+// keyset restricts and orders the selection on the primary key. Backward keysets are returned in descending order.
+func (q *GeneratedQueries) keyset(keyset Keyset, where string, args []interface{}) (string, []interface{}, string) {
+	key := q.dialect.Quote(q.keyColumn)
+	orderBy := "ORDER BY " + key
+	operator := ">"
+	if keyset.Backward {
+		orderBy += " DESC"
+		operator = "<"
+	}
+	if "" == keyset.Key {
+		return where, args, orderBy
+	}
+	args = append(args, keyset.Key)
+	condition := key + " " + operator + " " + q.dialect.Placeholder(len(args))
+	if "" == where {
+		return " WHERE " + condition, args, orderBy
+	}
+	return where + " AND " + condition, args, orderBy
+}
+
+func (q *GeneratedQueries) selectMatching(selection string, query Query) (string, []interface{}, error) {
+	if err := query.validate(); nil != err {
+		return "", nil, err
+	}
+	where, args, err := q.where(query.Filter)
+	if err != nil {
+		return "", nil, err
+	}
+	orderBy, err := q.orderBy(query.Sort)
+	if err != nil {
+		return "", nil, err
+	}
+	offset := query.Pagination.Offset()
+	if nil != query.Keyset {
+		where, args, orderBy = q.keyset(*query.Keyset, where, args)
+		offset = 0
+	}
+	nbArgs := len(args)
+	args = append(args, offset, query.Pagination.Limit())
+	return "SELECT " + selection + " FROM " + q.dialect.Quote(q.table) + where + " " + orderBy + " " + q.dialect.Paginate(nbArgs+1, nbArgs+2), args, nil
+}
+
+func (q *GeneratedQueries) GetAllEntitiesMatching(query Query) (string, []interface{}, error) {
+	return q.selectMatching(q.quotedColumns(), query)
+}
+
+func (q *GeneratedQueries) GetAllIDsMatching(query Query) (string, []interface{}, error) {
+	return q.selectMatching(q.dialect.Quote(q.keyColumn), query)
+}
+
+func (q *GeneratedQueries) TotalNumberOfEntitiesMatching(filter Filter) (string, []interface{}, error) {
+	where, args, err := q.where(filter)
+	if err != nil {
+		return "", nil, err
+	}
+	return "SELECT COUNT(*) FROM " + q.dialect.Quote(q.table) + where, args, nil
+}
@@ -0,0 +1,157 @@
+package rest_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/normegil/rest"
+	"github.com/normegil/rest/resttest"
+)
+
+type sqlEntity struct {
+	Identifier string `db:"id,pk" json:"id"`
+	Name       string `db:"name" json:"name"`
+	Size       int64  `db:"size" json:"size"`
+}
+
+func (e sqlEntity) ID() rest.Identifier {
+	if "" == e.Identifier {
+		return nil
+	}
+	return rest.StringIdentifier(e.Identifier)
+}
+
+func (e sqlEntity) WithID(id rest.Identifier) (rest.IdentifiableEntity, error) {
+	e.Identifier = id.String()
+	return e, nil
+}
+
+func TestGeneratedQueries(t *testing.T) {
+	columns := []string{"id", "name", "size"}
+	testcases := []struct {
+		dialect  rest.Dialect
+		queries  string
+		expected string
+	}{
+		{rest.SQLite, "getAllEntities", `SELECT "name", "size", "id" FROM "things" ORDER BY "id" LIMIT ?2 OFFSET ?1`},
+		{rest.SQLite, "update", `UPDATE "things" SET "name" = ?1, "size" = ?2 WHERE "id" = ?3`},
+		{rest.SQLite, "upsert", `INSERT INTO "things" ("name", "size", "id") VALUES (?1, ?2, ?3) ON CONFLICT ("id") DO UPDATE SET "name" = excluded."name", "size" = excluded."size"`},
+		{rest.PostgreSQL, "getAllIDs", `SELECT "id" FROM "things" ORDER BY "id" LIMIT $2 OFFSET $1`},
+		{rest.PostgreSQL, "insert", `INSERT INTO "things" ("name", "size", "id") VALUES ($1, $2, $3)`},
+		{rest.PostgreSQL, "get", `SELECT "name", "size", "id" FROM "things" WHERE "id" = $1`},
+		{rest.PostgreSQL, "getBatch", `SELECT "name", "size", "id" FROM "things" WHERE "id" IN ($1, $2)`},
+		{rest.MySQL, "getAllEntities", "SELECT `name`, `size`, `id` FROM `things` ORDER BY `id` LIMIT ?, ?"},
+		{rest.MySQL, "update", "UPDATE `things` SET `name` = ?, `size` = ? WHERE `id` = ?"},
+		{rest.MySQL, "upsert", "INSERT INTO `things` (`name`, `size`, `id`) VALUES (?, ?, ?) AS new ON DUPLICATE KEY UPDATE `name` = new.`name`, `size` = new.`size`"},
+		{rest.MySQL, "delete", "DELETE FROM `things` WHERE `id` = ?"},
+		{rest.MySQL, "count", "SELECT COUNT(*) FROM `things`"},
+	}
+	for _, testdata := range testcases {
+		t.Run(testdata.queries+": "+testdata.expected, func(t *testing.T) {
+			queries := rest.NewQueries(testdata.dialect, "things", "id", columns)
+			generated := map[string]string{
+				"getAllEntities": queries.GetAllEntities(),
+				"getAllIDs":      queries.GetAllIDs(),
+				"count":          queries.TotalNumberOfEntities(),
+				"get":            queries.Get(),
+				"getBatch":       queries.GetBatch(2),
+				"insert":         queries.Insert(),
+				"update":         queries.Update(),
+				"upsert":         queries.Upsert(),
+				"delete":         queries.Delete(),
+			}[testdata.queries]
+			if testdata.expected != generated {
+				t.Errorf("Query (%s) doesn't meet the expected result (%s)", generated, testdata.expected)
+			}
+		})
+	}
+}
+
+func TestGeneratedQueriesUpdateVersioned(t *testing.T) {
+	testcases := []struct {
+		dialect  rest.Dialect
+		expected string
+	}{
+		{rest.SQLite, `UPDATE "things" SET "name" = ?1, "size" = ?2, "version" = "version" + 1 WHERE "version" = ?3 AND "id" = ?4`},
+		{rest.MySQL, "UPDATE `things` SET `name` = ?, `size` = ?, `version` = `version` + 1 WHERE `version` = ? AND `id` = ?"},
+	}
+	for _, testdata := range testcases {
+		t.Run(testdata.expected, func(t *testing.T) {
+			queries := rest.NewQueries(testdata.dialect, "things", "id", []string{"id", "version", "name", "size"})
+			if "" != queries.UpdateVersioned() {
+				t.Errorf("Versioned update generated without version column: %s", queries.UpdateVersioned())
+			}
+			queries.SetVersionColumn("version")
+			if testdata.expected != queries.UpdateVersioned() {
+				t.Errorf("Query (%s) doesn't meet the expected result (%s)", queries.UpdateVersioned(), testdata.expected)
+			}
+		})
+	}
+}
+
+// transactionalQueries hides the upsert statement so DatabaseDAO.Set falls back on its transaction.
+type transactionalQueries struct {
+	rest.Queries
+}
+
+func TestDatabaseDAOWithGeneratedQueries(t *testing.T) {
+	mapper, err := rest.NewStructMapper(sqlEntity{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testcases := []struct {
+		name    string
+		queries rest.Queries
+	}{
+		{"Upsert", rest.NewMapperQueries(rest.SQLite, "things", mapper)},
+		{"Transaction", transactionalQueries{rest.NewMapperQueries(rest.SQLite, "things", mapper)}},
+	}
+	for _, testdata := range testcases {
+		t.Run(testdata.name, func(t *testing.T) {
+			resttest.TestDAO(t, resttest.DAOFixture{
+				NewDAO: func(t *testing.T) rest.DAO {
+					return newSQLiteDAO(t, mapper, testdata.queries)
+				},
+				NewEntity: func(seed int) rest.IdentifiableEntity {
+					return sqlEntity{Name: "entity-" + strconv.Itoa(seed), Size: int64(seed)}
+				},
+			})
+		})
+	}
+}
+
+func newSQLiteDAO(t *testing.T, mapper rest.Mapper, queries rest.Queries) *rest.DatabaseDAO {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	return newThingsDAO(t, db, mapper, queries)
+}
+
+// newSQLiteFileDAO stores things in a file, so that the DAO uses several connections concurrently.
+func newSQLiteFileDAO(t *testing.T, mapper rest.Mapper, queries rest.Queries) *rest.DatabaseDAO {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "things.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newThingsDAO(t, db, mapper, queries)
+}
+
+func newThingsDAO(t *testing.T, db *sql.DB, mapper rest.Mapper, queries rest.Queries) *rest.DatabaseDAO {
+	if _, err := db.Exec(`CREATE TABLE things (id TEXT PRIMARY KEY, name TEXT NOT NULL, size INTEGER NOT NULL, version INTEGER NOT NULL DEFAULT 0)`); nil != err {
+		t.Fatal(err)
+	}
+	dao, err := rest.NewDatabaseDAO(db, mapper, queries, rest.UUIDIdentifierGenerator{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		dao.Close()
+		db.Close()
+	})
+	return dao
+}
@@ -0,0 +1,249 @@
+// Package resttest provides helpers to verify implementations of the rest interfaces.
+package resttest
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/normegil/rest"
+)
+
+type DAOFixture struct {
+	// NewDAO returns an empty DAO. It is called once per test case.
+	NewDAO func(t *testing.T) rest.DAO
+	// NewEntity returns an entity without ID. Different seeds should give different entities.
+	NewEntity func(seed int) rest.IdentifiableEntity
+	// NewID returns an identifier unknown to the DAO. Defaults to a random UUID.
+	NewID func() rest.Identifier
+	// Equal compares an entity given to the DAO with one returned by it. Defaults to comparing their JSON representation.
+	Equal func(expected rest.IdentifiableEntity, actual rest.Entity) bool
+}
+
+// TestDAO verifies that the DAO built by fixture follows the contract of DatabaseDAO.
+func TestDAO(t *testing.T, fixture DAOFixture) {
+	if nil == fixture.NewID {
+		fixture.NewID = func() rest.Identifier {
+			return rest.StringIdentifier(uuid.Must(uuid.NewV4()).String())
+		}
+	}
+	if nil == fixture.Equal {
+		fixture.Equal = jsonEqual
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+		dao := fixture.NewDAO(t)
+		expectTotal(t, dao, 0)
+		ids, err := dao.GetAllIDs(pagination(0, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if 0 != len(ids) {
+			t.Errorf("Expected no ID but got %+v", ids)
+		}
+		entities, err := dao.GetAllEntities(pagination(0, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if 0 != len(entities) {
+			t.Errorf("Expected no entity but got %+v", entities)
+		}
+	})
+
+	t.Run("Get unknown ID", func(t *testing.T) {
+		dao := fixture.NewDAO(t)
+		populate(t, dao, fixture, 2)
+		_, err := dao.Get(fixture.NewID())
+		if !rest.IsNotFound(err) {
+			t.Errorf("Expected a not found error but got %+v", err)
+		}
+	})
+
+	t.Run("Set generates ID", func(t *testing.T) {
+		dao := fixture.NewDAO(t)
+		entity := fixture.NewEntity(0)
+		id, err := dao.Set(entity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if nil == id || "" == id.String() {
+			t.Fatalf("Expected a generated ID but got '%+v'", id)
+		}
+		expected, err := entity.WithID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectEntity(t, dao, fixture, expected)
+		expectTotal(t, dao, 1)
+	})
+
+	t.Run("Set inserts unknown ID", func(t *testing.T) {
+		dao := fixture.NewDAO(t)
+		populate(t, dao, fixture, 2)
+		entity, err := fixture.NewEntity(10).WithID(fixture.NewID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := dao.Set(entity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entity.ID().String() != id.String() {
+			t.Errorf("Returned ID (%s) doesn't meet the expected result (%s)", id, entity.ID())
+		}
+		expectEntity(t, dao, fixture, entity)
+		expectTotal(t, dao, 3)
+	})
+
+	t.Run("Set updates known ID", func(t *testing.T) {
+		dao := fixture.NewDAO(t)
+		ids := populate(t, dao, fixture, 3)
+		updated, err := fixture.NewEntity(10).WithID(ids[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := dao.Set(updated)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids[1].String() != id.String() {
+			t.Errorf("Returned ID (%s) doesn't meet the expected result (%s)", id, ids[1])
+		}
+		expectEntity(t, dao, fixture, updated)
+		expectTotal(t, dao, 3)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		dao := fixture.NewDAO(t)
+		ids := populate(t, dao, fixture, 3)
+		if err := dao.Delete(ids[1]); nil != err {
+			t.Fatal(err)
+		}
+		if _, err := dao.Get(ids[1]); !rest.IsNotFound(err) {
+			t.Errorf("Expected a not found error after deletion but got %+v", err)
+		}
+		expectTotal(t, dao, 2)
+		if err := dao.Delete(ids[1]); !rest.IsNotFound(err) {
+			t.Errorf("Expected a not found error when deleting twice but got %+v", err)
+		}
+		if err := dao.Delete(fixture.NewID()); !rest.IsNotFound(err) {
+			t.Errorf("Expected a not found error when deleting an unknown ID but got %+v", err)
+		}
+		expectTotal(t, dao, 2)
+	})
+
+	t.Run("Pagination", func(t *testing.T) {
+		dao := fixture.NewDAO(t)
+		ids := populate(t, dao, fixture, 5)
+		expectTotal(t, dao, 5)
+		testcases := []struct {
+			name       string
+			offset     int64
+			limit      int64
+			nbExpected int
+		}{
+			{"No limit", 0, 0, 5},
+			{"First page", 0, 2, 2},
+			{"Middle page", 2, 2, 2},
+			{"Last partial page", 4, 2, 1},
+			{"Exact end", 3, 2, 2},
+			{"Offset at end", 5, 2, 0},
+			{"Offset after end", 10, 2, 0},
+			{"Limit larger than collection", 1, 100, 4},
+		}
+		for _, testdata := range testcases {
+			t.Run(testdata.name, func(t *testing.T) {
+				p := pagination(testdata.offset, testdata.limit)
+				pageIDs, err := dao.GetAllIDs(p)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if testdata.nbExpected != len(pageIDs) {
+					t.Errorf("Number of IDs (%d) doesn't meet the expected result (%d)", len(pageIDs), testdata.nbExpected)
+				}
+				entities, err := dao.GetAllEntities(p)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if testdata.nbExpected != len(entities) {
+					t.Errorf("Number of entities (%d) doesn't meet the expected result (%d)", len(entities), testdata.nbExpected)
+				}
+			})
+		}
+
+		t.Run("Pages cover the collection", func(t *testing.T) {
+			seen := make(map[string]bool)
+			for offset := int64(0); offset < 5; offset += 2 {
+				pageIDs, err := dao.GetAllIDs(pagination(offset, 2))
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, id := range pageIDs {
+					if seen[id.String()] {
+						t.Errorf("ID '%s' returned in several pages", id)
+					}
+					seen[id.String()] = true
+				}
+			}
+			for _, id := range ids {
+				if !seen[id.String()] {
+					t.Errorf("ID '%s' never returned", id)
+				}
+			}
+		})
+	})
+}
+
+func pagination(offset int64, limit int64) rest.Pagination {
+	p := rest.Pagination{}
+	p.SetOffset(offset)
+	p.SetLimit(limit)
+	return p
+}
+
+func populate(t *testing.T, dao rest.DAO, fixture DAOFixture, nbEntities int) []rest.Identifier {
+	t.Helper()
+	ids := make([]rest.Identifier, 0, nbEntities)
+	for i := 0; i < nbEntities; i++ {
+		id, err := dao.Set(fixture.NewEntity(i))
+		if err != nil {
+			t.Fatalf("Populating DAO: %+v", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func expectTotal(t *testing.T, dao rest.DAO, expected int64) {
+	t.Helper()
+	total, err := dao.TotalNumberOfEntities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected != total {
+		t.Errorf("Total number of entities (%d) doesn't meet the expected result (%d)", total, expected)
+	}
+}
+
+func expectEntity(t *testing.T, dao rest.DAO, fixture DAOFixture, expected rest.IdentifiableEntity) {
+	t.Helper()
+	found, err := dao.Get(expected.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fixture.Equal(expected, found) {
+		t.Errorf("Entity (%+v) doesn't meet the expected result (%+v)", found, expected)
+	}
+}
+
+func jsonEqual(expected rest.IdentifiableEntity, actual rest.Entity) bool {
+	expectedJSON, err := json.Marshal(expected)
+	if err != nil {
+		return false
+	}
+	actualJSON, err := json.Marshal(actual)
+	if err != nil {
+		return false
+	}
+	return string(expectedJSON) == string(actualJSON)
+}
@@ -22,10 +22,40 @@ type Route interface {
 	Handler() httprouter.Handle
 }
 
+// DescribedRoute is implemented by routes documented in the OpenAPI document of Router.
+type DescribedRoute interface {
+	Route
+	Metadata() RouteMetadata
+}
+
+// RouteMetadata documents a route. Request and Response are prototypes of the bodies, a Schema, or a CollectionOf.
+type RouteMetadata struct {
+	Summary     string
+	Description string
+	Parameters  []Parameter
+	Request     interface{}
+	// RequestMediaTypes default to application/json when Request is set.
+	RequestMediaTypes []string
+	Response          interface{}
+	// ResponseMediaTypes default to application/json when Response is set.
+	ResponseMediaTypes []string
+	// Status of successful responses, 200 by default.
+	Status int
+}
+
+// MetadataOf returns the metadata of a DescribedRoute, or an empty description.
+func MetadataOf(route Route) RouteMetadata {
+	if described, ok := route.(DescribedRoute); ok {
+		return described.Metadata()
+	}
+	return RouteMetadata{}
+}
+
 type HttpRoute struct {
-	method  Method
-	path    Path
-	handler httprouter.Handle
+	method   Method
+	path     Path
+	handler  httprouter.Handle
+	metadata RouteMetadata
 }
 
 func (r HttpRoute) Method() Method {
@@ -40,6 +70,10 @@ func (r HttpRoute) Handler() httprouter.Handle {
 	return r.handler
 }
 
+func (r HttpRoute) Metadata() RouteMetadata {
+	return r.metadata
+}
+
 func NewRoute(method Method, path Path, handler httprouter.Handle) *HttpRoute {
 	return &HttpRoute{
 		method:  method,
@@ -47,3 +81,9 @@ func NewRoute(method Method, path Path, handler httprouter.Handle) *HttpRoute {
 		handler: handler,
 	}
 }
+
+// Describe sets the metadata of the route and returns it.
+func (r *HttpRoute) Describe(metadata RouteMetadata) *HttpRoute {
+	r.metadata = metadata
+	return r
+}
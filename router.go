@@ -1,31 +1,107 @@
 package rest
 
 import (
+	"context"
+	"io"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"fmt"
 	"github.com/julienschmidt/httprouter"
 	"github.com/pkg/errors"
-	"fmt"
 )
 
+// ServerOptions configure the http.Server built by Router. Zero values keep the net/http defaults.
+type ServerOptions struct {
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+}
+
 type Router struct {
-	Router *httprouter.Router
-	logger Logger
+	Router          *httprouter.Router
+	logger          Logger
+	baseURLResolver *BaseURLResolver
+	registry        *Registry
+	serverOptions   ServerOptions
+	lock            sync.Mutex
+	servers         []*http.Server
+	shutdown        bool
+	shutdownDone    chan struct{}
+	closers         []io.Closer
+	routes          []Route
+	hiddenRoutes    []Route
+	errorHandler    ErrorHandler
 }
 
+// NewRouter answers unmatched paths with a 404 and unmatched methods with a 405 listing the allowed ones, both as problems.
 func NewRouter() *Router {
-	return &Router{
-		Router: httprouter.New(),
+	router := &Router{
+		Router:          httprouter.New(),
+		baseURLResolver: &BaseURLResolver{},
+		registry:        NewRegistry(),
+		errorHandler:    ProblemErrorHandler{},
+		shutdownDone:    make(chan struct{}),
 	}
+	router.Router.HandleMethodNotAllowed = true
+	router.Router.NotFound = http.HandlerFunc(router.notFound)
+	router.Router.MethodNotAllowed = http.HandlerFunc(router.methodNotAllowed)
+	return router
 }
 
 func (r *Router) SetLogger(log Logger) {
 	r.logger = log
 }
 
+// SetBaseURLResolver configures how resource URLs are built, e.g. which proxies are trusted.
+func (r *Router) SetBaseURLResolver(resolver *BaseURLResolver) {
+	r.baseURLResolver = resolver
+}
+
+// SetErrorHandler configures how 404 and 405 responses are written, ProblemErrorHandler by default.
+func (r *Router) SetErrorHandler(handler ErrorHandler) {
+	if nil == handler {
+		handler = ProblemErrorHandler{}
+	}
+	r.errorHandler = handler
+}
+
+// SetServerOptions configures the servers started afterwards.
+func (r *Router) SetServerOptions(options ServerOptions) {
+	r.serverOptions = options
+}
+
+// CloseOnShutdown adds a resource closed by Shutdown once servers are stopped.
+// Registered DAOs implementing io.Closer, or with a Close method without result like DatabaseDAO, are closed without being added.
+func (r *Router) CloseOnShutdown(closer io.Closer) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.closers = append(r.closers, closer)
+}
+
+// Register adds the routes of the controller. Controllers implementing RegistryBinder can then expand relations to each other.
+// Routes are only listed once all of them are registered, as httprouter panics on conflicting paths.
 func (r *Router) Register(ctrl Controller) error {
-	for _, route := range ctrl.Routes() {
+	if binder, ok := ctrl.(RegistryBinder); ok {
+		binder.BindRegistry(r.registry)
+	}
+	routes := ctrl.Routes()
+	for _, route := range routes {
+		if !supportedMethods[route.Method()] {
+			return fmt.Errorf("HTTP Method not supported {method:%s;path:%s}", route.Method(), route.Path())
+		}
+	}
+	for _, route := range routes {
 		switch route.Method() {
 		case HEAD:
 			r.Router.HEAD(string(route.Path()), route.Handler())
@@ -45,9 +121,102 @@ func (r *Router) Register(ctrl Controller) error {
 			return fmt.Errorf("HTTP Method not supported {method:%s;path:%s}", route.Method(), route.Path())
 		}
 	}
+	r.lock.Lock()
+	r.routes = append(r.routes, routes...)
+	r.lock.Unlock()
 	return nil
 }
 
+var supportedMethods = map[Method]bool{HEAD: true, GET: true, POST: true, PUT: true, DELETE: true, OPTIONS: true, PATCH: true}
+
+// Routes lists the registered routes, in registration order.
+func (r *Router) Routes() []Route {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]Route{}, r.routes...)
+}
+
+// OpenAPI describes the registered routes.
+func (r *Router) OpenAPI(info OpenAPIInfo) *OpenAPIDocument {
+	return NewOpenAPIDocument(info, r.Routes())
+}
+
+// ServeOpenAPI serves the OpenAPI document of the registered routes on GET path. The path itself isn't documented.
+func (r *Router) ServeOpenAPI(path string, info OpenAPIInfo) {
+	handler := openAPIHandler(info, r.Routes)
+	r.lock.Lock()
+	r.hiddenRoutes = append(r.hiddenRoutes, NewRoute(GET, Path(path), nil))
+	r.lock.Unlock()
+	r.Router.GET(path, func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		handler.ServeHTTP(w, req)
+	})
+}
+
+func (r *Router) notFound(w http.ResponseWriter, req *http.Request) {
+	r.handleError(w, NewError(KindNotFound, nil, "No resource found at '%s'", req.URL.Path))
+}
+
+func (r *Router) methodNotAllowed(w http.ResponseWriter, req *http.Request) {
+	allowed := r.allowedMethods(req.URL.Path)
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	r.handleError(w, NewError(KindMethodNotAllowed, nil, "Method %s not allowed at '%s' (allowed: %s)", req.Method, req.URL.Path, strings.Join(allowed, ", ")))
+}
+
+func (r *Router) handleError(w http.ResponseWriter, err error) {
+	if e := r.errorHandler.Handle(w, err); nil != e {
+		if nil != r.logger {
+			r.logger.Printf("Error while writing error response -{ %s }-: %s", err.Error(), e.Error())
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// allowedMethods lists, sorted, the methods of the routes matching path.
+// OPTIONS is included when httprouter answers it automatically.
+func (r *Router) allowedMethods(path string) []string {
+	r.lock.Lock()
+	routes := append(append([]Route{}, r.routes...), r.hiddenRoutes...)
+	r.lock.Unlock()
+
+	methods := make(map[string]bool)
+	for _, route := range routes {
+		if matchesPath(string(route.Path()), path) {
+			methods[string(route.Method())] = true
+		}
+	}
+	if len(methods) > 0 && r.Router.HandleOPTIONS {
+		methods[string(OPTIONS)] = true
+	}
+	allowed := make([]string, 0, len(methods))
+	for method := range methods {
+		allowed = append(allowed, method)
+	}
+	sort.Strings(allowed)
+	return allowed
+}
+
+// matchesPath reports whether path matches an httprouter pattern, with ':name' and '*name' parameters.
+func matchesPath(pattern string, path string) bool {
+	patternSegments := strings.Split(pattern, "/")
+	pathSegments := strings.Split(path, "/")
+	for i, segment := range patternSegments {
+		if strings.HasPrefix(segment, "*") {
+			return i < len(pathSegments)
+		}
+		if i >= len(pathSegments) {
+			return false
+		}
+		if strings.HasPrefix(segment, ":") {
+			if "" == pathSegments[i] {
+				return false
+			}
+		} else if segment != pathSegments[i] {
+			return false
+		}
+	}
+	return len(patternSegments) == len(pathSegments)
+}
+
 func (r *Router) Listen(port int) error {
 	return r.ListenWithMiddleware(port, func(h http.Handler) http.Handler {
 		return h
@@ -55,15 +224,141 @@ func (r *Router) Listen(port int) error {
 }
 
 func (r *Router) ListenWithMiddleware(port int, withMiddleware func(http.Handler) http.Handler) error {
+	listener, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return errors.Wrapf(err, "Error while Listening on %d", port)
+	}
+	if err = r.ServeWithMiddleware(listener, withMiddleware); nil != err {
+		return errors.Wrapf(err, "Error while Listening on %d", port)
+	}
+	return nil
+}
+
+// Serve answers requests accepted by listener until Shutdown, in which case it returns nil once Shutdown is over.
+// Once the router is shut down, Serve closes listener and returns nil right away.
+func (r *Router) Serve(listener net.Listener) error {
+	return r.ServeWithMiddleware(listener, func(h http.Handler) http.Handler {
+		return h
+	})
+}
+
+func (r *Router) ServeWithMiddleware(listener net.Listener, withMiddleware func(http.Handler) http.Handler) error {
+	server := r.newServer(r.handler(withMiddleware))
+	if err := server.Serve(listener); nil != err && http.ErrServerClosed != err {
+		return errors.Wrapf(err, "Serving on %s", listener.Addr())
+	}
+	r.waitShutdown()
+	return nil
+}
+
+// waitShutdown returns once Shutdown has drained in-flight requests and closed the owned resources, so that programs
+// returning from Serve don't exit in the middle of it.
+func (r *Router) waitShutdown() {
+	<-r.shutdownDone
+}
+
+func (r *Router) handler(withMiddleware func(http.Handler) http.Handler) http.Handler {
 	var handler http.Handler
 	handler = r.Router
 	if nil != r.logger {
 		handler = RequestLogger(r.logger, handler)
 	}
-	handler = URLContructor(DefaultHeaders(withMiddleware(handler)))
+	return URLContructorWithResolver(r.baseURLResolver, DefaultHeaders(withMiddleware(handler)))
+}
 
-	if err := http.ListenAndServe(":"+strconv.Itoa(port), handler); nil != err {
-		return errors.Wrapf(err, "Error while Listening on %d", port)
+// newServer builds a server with the configured options and tracks it for Shutdown, or shuts it down if the router already is.
+func (r *Router) newServer(handler http.Handler) *http.Server {
+	server := &http.Server{
+		Handler:           handler,
+		ReadTimeout:       r.serverOptions.ReadTimeout,
+		ReadHeaderTimeout: r.serverOptions.ReadHeaderTimeout,
+		WriteTimeout:      r.serverOptions.WriteTimeout,
+		IdleTimeout:       r.serverOptions.IdleTimeout,
+		MaxHeaderBytes:    r.serverOptions.MaxHeaderBytes,
 	}
-	return nil
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.shutdown {
+		// Serving a server already shut down returns http.ErrServerClosed right away
+		server.Shutdown(context.Background())
+		return server
+	}
+	r.servers = append(r.servers, server)
+	return server
+}
+
+// Shutdown stops accepting requests, waits for in-flight ones until ctx is done, then closes the owned resources.
+// Resources, like a DAO registered under several controllers, are closed once. Later calls wait for the first one.
+func (r *Router) Shutdown(ctx context.Context) error {
+	r.lock.Lock()
+	ongoing := r.shutdown
+	r.shutdown = true
+	servers := r.servers
+	r.servers = nil
+	closers := r.closers
+	r.closers = nil
+	r.lock.Unlock()
+	if ongoing {
+		select {
+		case <-r.shutdownDone:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	defer close(r.shutdownDone)
+
+	var firstErr error
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); nil != err && nil == firstErr {
+			firstErr = errors.Wrapf(err, "Shutting server down")
+		}
+	}
+	resources := make([]interface{}, 0, len(closers))
+	for _, closer := range closers {
+		resources = append(resources, closer)
+	}
+	for _, dao := range r.registry.all() {
+		resources = append(resources, dao)
+	}
+	closed := make(map[interface{}]bool)
+	for _, resource := range resources {
+		// Only pointers are compared, as other values may not be comparable
+		if reflect.Ptr == reflect.ValueOf(resource).Kind() {
+			if closed[resource] {
+				continue
+			}
+			closed[resource] = true
+		}
+		var err error
+		switch closer := resource.(type) {
+		case io.Closer:
+			err = closer.Close()
+		case interface{ Close() }:
+			// Like DatabaseDAO, which doesn't report errors
+			closer.Close()
+		}
+		if nil != err && nil == firstErr {
+			firstErr = errors.Wrapf(err, "Closing %T", resource)
+		}
+	}
+	return firstErr
+}
+
+// ShutdownOnSignal calls Shutdown, waiting up to timeout for in-flight requests, when one of signals (SIGINT by default) is received.
+func (r *Router) ShutdownOnSignal(timeout time.Duration, signals ...os.Signal) {
+	if 0 == len(signals) {
+		signals = []os.Signal{os.Interrupt}
+	}
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, signals...)
+	go func() {
+		<-received
+		signal.Stop(received)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if err := r.Shutdown(ctx); nil != err && nil != r.logger {
+			r.logger.Printf("Shutdown: %s", err.Error())
+		}
+	}()
 }
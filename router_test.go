@@ -0,0 +1,244 @@
+package rest_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/normegil/rest"
+)
+
+type routesController struct {
+	basePath string
+	routes   []rest.Route
+}
+
+func (c routesController) Routes() []rest.Route {
+	return c.routes
+}
+
+func (c routesController) BasePath() string {
+	return c.basePath
+}
+
+type closingDAO struct {
+	*rest.MemoryDAO
+	closes int
+}
+
+func (d *closingDAO) Close() error {
+	d.closes++
+	return nil
+}
+
+func TestRouterGracefulShutdown(t *testing.T) {
+	router := rest.NewRouter()
+	router.SetServerOptions(rest.ServerOptions{ReadHeaderTimeout: time.Second, IdleTimeout: time.Second})
+	started := make(chan struct{})
+	release := make(chan struct{})
+	if err := router.Register(routesController{basePath: "slow", routes: []rest.Route{
+		rest.NewRoute(rest.GET, "/slow", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+			close(started)
+			<-release
+			w.Write([]byte("done"))
+		}),
+	}}); nil != err {
+		t.Fatal(err)
+	}
+	dao := &closingDAO{MemoryDAO: rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{})}
+	for _, basePath := range []string{"things", "others"} {
+		if err := router.Register(rest.NewController(basePath, dao, nil, nil)); nil != err {
+			t.Fatal(err)
+		}
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- router.Serve(listener)
+	}()
+
+	type response struct {
+		body string
+		err  error
+	}
+	responses := make(chan response, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String() + "/slow")
+		if err != nil {
+			responses <- response{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		responses <- response{body: string(body), err: err}
+	}()
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- router.Shutdown(context.Background())
+	}()
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown returned before the in-flight request completed: %+v", err)
+	case err := <-served:
+		t.Fatalf("Serve returned before the in-flight request completed: %+v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+
+	if resp := <-responses; nil != resp.err || "done" != resp.body {
+		t.Errorf("In-flight request wasn't drained: %s (%+v)", resp.body, resp.err)
+	}
+	// Serve only returns once Shutdown is over, DAOs included
+	if err = <-served; nil != err {
+		t.Errorf("Serve returned %+v after a graceful shutdown", err)
+	}
+	if 1 != dao.closes {
+		t.Errorf("Registered DAO was closed %d times instead of once on shutdown", dao.closes)
+	}
+	if err = <-shutdown; nil != err {
+		t.Error(err)
+	}
+}
+
+// silentClosingDAO closes like DatabaseDAO, without reporting errors.
+type silentClosingDAO struct {
+	*rest.MemoryDAO
+	closed bool
+}
+
+func (d *silentClosingDAO) Close() {
+	d.closed = true
+}
+
+func TestRouterServeAfterShutdown(t *testing.T) {
+	router := rest.NewRouter()
+	dao := &silentClosingDAO{MemoryDAO: rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{})}
+	if err := router.Register(rest.NewController("things", dao, nil, nil)); nil != err {
+		t.Fatal(err)
+	}
+	if err := router.Shutdown(context.Background()); nil != err {
+		t.Fatal(err)
+	}
+	if !dao.closed {
+		t.Error("Registered DAO wasn't closed on shutdown")
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- router.Serve(listener)
+	}()
+	select {
+	case err := <-served:
+		if nil != err {
+			t.Errorf("Serve after shutdown returned %+v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Serve after shutdown didn't return")
+	}
+	if conn, err := net.Dial("tcp", listener.Addr().String()); nil == err {
+		conn.Close()
+		t.Error("Listener still accepts connections after shutdown")
+	}
+}
+
+func TestRouterRegisterUnsupportedMethod(t *testing.T) {
+	router := rest.NewRouter()
+	noop := func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {}
+	err := router.Register(routesController{basePath: "things", routes: []rest.Route{
+		rest.NewRoute(rest.GET, "/things", noop),
+		rest.NewRoute(rest.Method("TRACE"), "/things", noop),
+	}})
+	if nil == err {
+		t.Fatal("Expected an error for an unsupported method")
+	}
+	if routes := router.Routes(); 0 != len(routes) {
+		t.Errorf("Failed registration left routes: %+v", routes)
+	}
+	result := httptest.NewRecorder()
+	router.Router.ServeHTTP(result, httptest.NewRequest("GET", "http://localhost/things", nil))
+	if http.StatusNotFound != result.Code {
+		t.Errorf("Route of a failed registration answered %d instead of %d", result.Code, http.StatusNotFound)
+	}
+}
+
+func TestRouterRegisterConflictingRoutes(t *testing.T) {
+	router := rest.NewRouter()
+	noop := func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {}
+	func() {
+		defer func() {
+			if nil == recover() {
+				t.Error("Expected httprouter to panic on conflicting routes")
+			}
+		}()
+		router.Register(routesController{basePath: "things", routes: []rest.Route{
+			rest.NewRoute(rest.GET, "/things", noop),
+			rest.NewRoute(rest.GET, "/things", noop),
+		}})
+	}()
+	if routes := router.Routes(); 0 != len(routes) {
+		t.Errorf("Failed registration left routes: %+v", routes)
+	}
+}
+
+func TestRouterNotFoundAndMethodNotAllowed(t *testing.T) {
+	router := rest.NewRouter()
+	noop := func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {}
+	if err := router.Register(routesController{basePath: "things", routes: []rest.Route{
+		rest.NewRoute(rest.GET, "/things", noop),
+		rest.NewRoute(rest.POST, "/things", noop),
+		rest.NewRoute(rest.PUT, "/things/:id", noop),
+		rest.NewRoute(rest.GET, "/things/:id", noop),
+		rest.NewRoute(rest.DELETE, "/things/:id", noop),
+	}}); nil != err {
+		t.Fatal(err)
+	}
+	testcases := []struct {
+		method string
+		path   string
+		status int
+		allow  string
+	}{
+		{"GET", "/unknown", http.StatusNotFound, ""},
+		{"GET", "/things/1/unknown", http.StatusNotFound, ""},
+		{"DELETE", "/things", http.StatusMethodNotAllowed, "GET, OPTIONS, POST"},
+		{"POST", "/things/1", http.StatusMethodNotAllowed, "DELETE, GET, OPTIONS, PUT"},
+	}
+	for _, testdata := range testcases {
+		t.Run(testdata.method+" "+testdata.path, func(t *testing.T) {
+			result := httptest.NewRecorder()
+			router.Router.ServeHTTP(result, httptest.NewRequest(testdata.method, "http://localhost"+testdata.path, nil))
+			if testdata.status != result.Code {
+				t.Errorf("Status (%d) doesn't meet the expected result (%d)", result.Code, testdata.status)
+			}
+			if rest.ProblemMediaType != result.Header().Get("Content-Type") {
+				t.Errorf("Content-Type (%s) doesn't meet the expected result (%s)", result.Header().Get("Content-Type"), rest.ProblemMediaType)
+			}
+			if testdata.allow != result.Header().Get("Allow") {
+				t.Errorf("Allow (%s) doesn't meet the expected result (%s)", result.Header().Get("Allow"), testdata.allow)
+			}
+			var problem rest.Problem
+			if err := json.Unmarshal(result.Body.Bytes(), &problem); nil != err {
+				t.Fatal(err)
+			}
+			if testdata.status != problem.Status {
+				t.Errorf("Problem status (%d) doesn't meet the expected result (%d)", problem.Status, testdata.status)
+			}
+		})
+	}
+}
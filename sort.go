@@ -0,0 +1,81 @@
+package rest
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+type SortField struct {
+	Field      string
+	Descending bool
+}
+
+// ParseSort reads the `sort=field,-otherField` query parameter. A leading '-' orders a field descending.
+func ParseSort(params url.Values, allowedFields []string) ([]SortField, error) {
+	sortStr := params.Get("sort")
+	if "" == sortStr {
+		return nil, nil
+	}
+	allowed := make(map[string]bool)
+	for _, field := range allowedFields {
+		allowed[field] = true
+	}
+	fields := make([]SortField, 0)
+	for _, field := range strings.Split(sortStr, ",") {
+		sortField := SortField{Field: strings.TrimSpace(field)}
+		if strings.HasPrefix(sortField.Field, "-") {
+			sortField.Field = sortField.Field[1:]
+			sortField.Descending = true
+		}
+		if !allowed[sortField.Field] {
+			return nil, NewError(KindValidation, nil, "Sorting on '%s' is not allowed", sortField.Field)
+		}
+		fields = append(fields, sortField)
+	}
+	return fields, nil
+}
+
+// sortRepresentations stably orders ids using the JSON representations of their entities.
+func sortRepresentations(ids []string, representations map[string]interface{}, fields []SortField) {
+	sort.SliceStable(ids, func(i, j int) bool {
+		for _, field := range fields {
+			left, _ := lookupPath(representations[ids[i]], field.Field)
+			right, _ := lookupPath(representations[ids[j]], field.Field)
+			comparison := compareJSONValues(left, right)
+			if 0 == comparison {
+				continue
+			}
+			if field.Descending {
+				return comparison > 0
+			}
+			return comparison < 0
+		}
+		return false
+	})
+}
+
+func compareJSONValues(left interface{}, right interface{}) int {
+	if nil == left || nil == right {
+		switch {
+		case nil == left && nil == right:
+			return 0
+		case nil == left:
+			return -1
+		}
+		return 1
+	}
+	leftNumber, leftIsNumber := left.(float64)
+	rightNumber, rightIsNumber := right.(float64)
+	if leftIsNumber && rightIsNumber {
+		switch {
+		case leftNumber < rightNumber:
+			return -1
+		case leftNumber > rightNumber:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(fmt.Sprint(left), fmt.Sprint(right))
+}
@@ -0,0 +1,83 @@
+package rest_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/normegil/rest"
+)
+
+func TestDefaultControllerSortedGetAll(t *testing.T) {
+	mapper, err := rest.NewStructMapper(sqlEntity{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	daos := map[string]rest.DAO{
+		"Memory":   rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{}),
+		"Database": newSQLiteDAO(t, mapper, rest.NewMapperQueries(rest.SQLite, "things", mapper)),
+	}
+	for daoName, dao := range daos {
+		t.Run(daoName, func(t *testing.T) {
+			for i, size := range []int64{3, 1, 4, 1, 5} {
+				if _, err := dao.Set(sqlEntity{Name: "entity-" + strconv.Itoa(i), Size: size}); nil != err {
+					t.Fatal(err)
+				}
+			}
+			ctrl := rest.NewController("things", dao, nil, nil)
+			ctrl.SortableFields = []string{"name", "size"}
+
+			request := httptest.NewRequest("GET", "http://localhost/things?expand=true&sort=-size,name&offset=1&limit=3", nil)
+			result := httptest.NewRecorder()
+			ctrl.GetAll(result, request, httprouter.Params{})
+			if http.StatusOK != result.Code {
+				t.Fatalf("GetAll returned %d: %s", result.Code, result.Body.String())
+			}
+			var response struct {
+				Next  string      `json:"next"`
+				Items []sqlEntity `json:"items"`
+			}
+			if err := json.Unmarshal(result.Body.Bytes(), &response); nil != err {
+				t.Fatal(err)
+			}
+			expected := []string{"entity-2", "entity-0", "entity-1"}
+			if len(expected) != len(response.Items) {
+				t.Fatalf("Items (%+v) don't meet the expected names (%v)", response.Items, expected)
+			}
+			for i, name := range expected {
+				if name != response.Items[i].Name {
+					t.Errorf("Item %d (%s) doesn't meet the expected result (%s)", i, response.Items[i].Name, name)
+				}
+			}
+			next, err := url.Parse(response.Next)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if "-size,name" != next.Query().Get("sort") || "4" != next.Query().Get("offset") {
+				t.Errorf("Next link (%s) doesn't keep the sort parameter", response.Next)
+			}
+
+			request = httptest.NewRequest("GET", "http://localhost/things?sort=unknown", nil)
+			result = httptest.NewRecorder()
+			ctrl.GetAll(result, request, httprouter.Params{})
+			if http.StatusBadRequest != result.Code {
+				t.Errorf("Sorting on an unknown field returned %d instead of %d", result.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestDefaultControllerGetAllWithoutSortableFields(t *testing.T) {
+	dao := struct{ rest.DAO }{rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{})}
+	ctrl := rest.NewController("things", dao, nil, nil)
+	request := httptest.NewRequest("GET", "http://localhost/things?sort=name", nil)
+	result := httptest.NewRecorder()
+	ctrl.GetAll(result, request, httprouter.Params{})
+	if http.StatusOK != result.Code {
+		t.Errorf("GetAll with a sort parameter returned %d instead of %d: %s", result.Code, http.StatusOK, result.Body.String())
+	}
+}
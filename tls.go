@@ -0,0 +1,157 @@
+package rest
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// TLSOptions configure ListenTLS and ServeTLS. The certificate comes from CertFile and KeyFile, or from Config.
+type TLSOptions struct {
+	CertFile string
+	KeyFile  string
+	Config   *tls.Config
+	// ClientCAFile or ClientCAs enable mutual TLS: clients must present a certificate signed by one of these authorities.
+	ClientCAFile string
+	ClientCAs    *x509.CertPool
+	// RedirectPort, when not 0, makes ListenTLS redirect plain HTTP requests received on this port to HTTPS.
+	RedirectPort int
+}
+
+// Build returns the server TLS configuration, with HTTP/2 enabled unless Config restricts NextProtos.
+func (o TLSOptions) Build() (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if nil != o.Config {
+		config = o.Config.Clone()
+	}
+	if "" != o.CertFile || "" != o.KeyFile {
+		certificate, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Loading certificate {cert:%s;key:%s}", o.CertFile, o.KeyFile)
+		}
+		config.Certificates = append(config.Certificates, certificate)
+	}
+	if 0 == len(config.Certificates) && nil == config.GetCertificate && nil == config.GetConfigForClient {
+		return nil, fmt.Errorf("No server certificate: set CertFile and KeyFile or Config")
+	}
+
+	clientCAs := o.ClientCAs
+	if "" != o.ClientCAFile {
+		pem, err := ioutil.ReadFile(o.ClientCAFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Reading client CA file %s", o.ClientCAFile)
+		}
+		if nil == clientCAs {
+			clientCAs = x509.NewCertPool()
+		}
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificate found in client CA file %s", o.ClientCAFile)
+		}
+	}
+	if nil != clientCAs {
+		config.ClientCAs = clientCAs
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	if 0 == len(config.NextProtos) {
+		config.NextProtos = []string{"h2", "http/1.1"}
+	}
+	return config, nil
+}
+
+// ListenTLS serves HTTPS on port, and redirections to it on options.RedirectPort when set, until Shutdown.
+// The redirection is only served once HTTPS listens, and stops if serving HTTPS fails.
+func (r *Router) ListenTLS(port int, options TLSOptions) error {
+	config, err := options.Build()
+	if err != nil {
+		return errors.Wrapf(err, "Error while Listening on %d", port)
+	}
+	listener, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return errors.Wrapf(err, "Error while Listening on %d", port)
+	}
+	var redirect *http.Server
+	if 0 != options.RedirectPort {
+		redirectListener, err := net.Listen("tcp", ":"+strconv.Itoa(options.RedirectPort))
+		if err != nil {
+			listener.Close()
+			return errors.Wrapf(err, "Error while Listening on %d", options.RedirectPort)
+		}
+		redirect = r.newServer(RedirectHandler(port))
+		go func() {
+			if err := serveRedirect(redirect, redirectListener); nil != err && nil != r.logger {
+				r.logger.Printf("Redirecting to HTTPS: %s", err.Error())
+			}
+		}()
+	}
+	if err = r.serveTLS(listener, config); nil != err {
+		if nil != redirect {
+			redirect.Close()
+		}
+		return errors.Wrapf(err, "Error while Listening on %d", port)
+	}
+	return nil
+}
+
+// ServeTLS answers HTTPS requests accepted by listener until Shutdown, in which case it returns nil once Shutdown is over.
+func (r *Router) ServeTLS(listener net.Listener, options TLSOptions) error {
+	config, err := options.Build()
+	if err != nil {
+		return err
+	}
+	return r.serveTLS(listener, config)
+}
+
+func (r *Router) serveTLS(listener net.Listener, config *tls.Config) error {
+	server := r.newServer(r.handler(func(h http.Handler) http.Handler {
+		return h
+	}))
+	server.TLSConfig = config
+	if err := server.ServeTLS(listener, "", ""); nil != err && http.ErrServerClosed != err {
+		return errors.Wrapf(err, "Serving TLS on %s", listener.Addr())
+	}
+	r.waitShutdown()
+	return nil
+}
+
+// ServeRedirect redirects the requests accepted by listener to HTTPS on tlsPort, until Shutdown is over.
+func (r *Router) ServeRedirect(listener net.Listener, tlsPort int) error {
+	if err := serveRedirect(r.newServer(RedirectHandler(tlsPort)), listener); nil != err {
+		return err
+	}
+	r.waitShutdown()
+	return nil
+}
+
+func serveRedirect(server *http.Server, listener net.Listener) error {
+	if err := server.Serve(listener); nil != err && http.ErrServerClosed != err {
+		return errors.Wrapf(err, "Serving redirections on %s", listener.Addr())
+	}
+	return nil
+}
+
+// RedirectHandler redirects requests to the same URL on HTTPS and tlsPort. Unsafe methods keep their method and body.
+func RedirectHandler(tlsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = strings.TrimSuffix(strings.TrimPrefix(r.Host, "["), "]")
+		}
+		if 443 != tlsPort {
+			host = net.JoinHostPort(host, strconv.Itoa(tlsPort))
+		}
+		target := "https://" + host + r.URL.RequestURI()
+		status := http.StatusPermanentRedirect
+		if http.MethodGet == r.Method || http.MethodHead == r.Method {
+			status = http.StatusMovedPermanently
+		}
+		http.Redirect(w, r, target, status)
+	})
+}
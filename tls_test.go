@@ -0,0 +1,244 @@
+package rest_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/normegil/rest"
+)
+
+type testCertificate struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+	der         []byte
+}
+
+func (c testCertificate) tls() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key, Leaf: c.certificate}
+}
+
+// newTestCertificate generates a certificate for 127.0.0.1, self-signed when parent is nil.
+func newTestCertificate(t *testing.T, parent *testCertificate, isCA bool) testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "rest test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := template, key
+	if nil != parent {
+		signer, signerKey = parent.certificate, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testCertificate{certificate: certificate, key: key, der: der}
+}
+
+// writeCertificateFiles writes the PEM encoded certificate and key into temporary files.
+func writeCertificateFiles(t *testing.T, certificate testCertificate) (string, string) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	keyDER, err := x509.MarshalECPrivateKey(certificate.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.der}), 0600); nil != err {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); nil != err {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func serveTLS(t *testing.T, options rest.TLSOptions) string {
+	router := rest.NewRouter()
+	if err := router.Register(routesController{basePath: "proto", routes: []rest.Route{
+		rest.NewRoute(rest.GET, "/proto", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+			w.Write([]byte(r.Proto))
+		}),
+	}}); nil != err {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- router.ServeTLS(listener, options)
+	}()
+	t.Cleanup(func() {
+		if err := router.Shutdown(context.Background()); nil != err {
+			t.Error(err)
+		}
+		if err := <-served; nil != err {
+			t.Errorf("ServeTLS returned %+v after shutdown", err)
+		}
+	})
+	return "https://" + listener.Addr().String() + "/proto"
+}
+
+func tlsClient(roots *x509.CertPool, certificates ...tls.Certificate) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: roots, Certificates: certificates},
+		ForceAttemptHTTP2: true,
+	}}
+}
+
+func getBody(client *http.Client, url string) (string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func TestRouterServeTLS(t *testing.T) {
+	ca := newTestCertificate(t, nil, true)
+	server := newTestCertificate(t, &ca, false)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.certificate)
+
+	certFile, keyFile := writeCertificateFiles(t, server)
+
+	t.Run("Certificate files with HTTP/2", func(t *testing.T) {
+		url := serveTLS(t, rest.TLSOptions{CertFile: certFile, KeyFile: keyFile})
+		body, err := getBody(tlsClient(roots), url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if "HTTP/2.0" != body {
+			t.Errorf("Protocol (%s) doesn't meet the expected result (%s)", body, "HTTP/2.0")
+		}
+	})
+
+	t.Run("Mutual TLS", func(t *testing.T) {
+		url := serveTLS(t, rest.TLSOptions{Config: &tls.Config{Certificates: []tls.Certificate{server.tls()}}, ClientCAs: roots})
+		if _, err := getBody(tlsClient(roots), url); nil == err {
+			t.Error("Request without client certificate was accepted")
+		}
+		client := newTestCertificate(t, &ca, false)
+		if _, err := getBody(tlsClient(roots, client.tls()), url); nil != err {
+			t.Errorf("Request with a client certificate failed: %+v", err)
+		}
+	})
+
+	t.Run("No certificate", func(t *testing.T) {
+		if _, err := (rest.TLSOptions{}).Build(); nil == err {
+			t.Error("Expected an error without certificate")
+		}
+	})
+}
+
+// freePort returns a port nothing listens on.
+func freePort(t *testing.T) int {
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestRouterListenTLSFailure(t *testing.T) {
+	ca := newTestCertificate(t, nil, true)
+	certFile, keyFile := writeCertificateFiles(t, newTestCertificate(t, &ca, false))
+	busy, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+
+	testcases := []struct {
+		name    string
+		port    int
+		options rest.TLSOptions
+	}{
+		{"Port in use", busy.Addr().(*net.TCPAddr).Port, rest.TLSOptions{CertFile: certFile, KeyFile: keyFile}},
+		{"Invalid certificate", freePort(t), rest.TLSOptions{CertFile: keyFile, KeyFile: certFile}},
+	}
+	for _, testdata := range testcases {
+		t.Run(testdata.name, func(t *testing.T) {
+			options := testdata.options
+			options.RedirectPort = freePort(t)
+			listened := make(chan error, 1)
+			go func() {
+				listened <- rest.NewRouter().ListenTLS(testdata.port, options)
+			}()
+			select {
+			case err := <-listened:
+				if nil == err {
+					t.Error("Expected an error")
+				}
+			case <-time.After(time.Second):
+				t.Fatal("ListenTLS didn't fail")
+			}
+			listener, err := net.Listen("tcp", ":"+strconv.Itoa(options.RedirectPort))
+			if err != nil {
+				t.Fatalf("Redirection port is still held: %+v", err)
+			}
+			listener.Close()
+		})
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	testcases := []struct {
+		method   string
+		url      string
+		port     int
+		status   int
+		location string
+	}{
+		{"GET", "http://example.com/things?limit=2", 443, http.StatusMovedPermanently, "https://example.com/things?limit=2"},
+		{"GET", "http://example.com:8080/things", 8443, http.StatusMovedPermanently, "https://example.com:8443/things"},
+		{"POST", "http://example.com/things", 443, http.StatusPermanentRedirect, "https://example.com/things"},
+	}
+	for _, testdata := range testcases {
+		t.Run(testdata.method+" "+testdata.url, func(t *testing.T) {
+			result := httptest.NewRecorder()
+			rest.RedirectHandler(testdata.port).ServeHTTP(result, httptest.NewRequest(testdata.method, testdata.url, nil))
+			if testdata.status != result.Code || testdata.location != result.Header().Get("Location") {
+				t.Errorf("Redirection (%d %s) doesn't meet the expected result (%d %s)", result.Code, result.Header().Get("Location"), testdata.status, testdata.location)
+			}
+		})
+	}
+}
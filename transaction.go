@@ -0,0 +1,79 @@
+package rest
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	maxTransactionAttempts = 5
+	transactionRetryDelay  = 10 * time.Millisecond
+)
+
+// Transactor is implemented by DAOs able to group several operations atomically.
+// DAO calls made with the context received by fn take part in the transaction.
+type Transactor interface {
+	InTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// InTransaction runs fn in a transaction when dao supports it, or directly otherwise.
+func InTransaction(ctx context.Context, dao DAO, fn func(ctx context.Context) error) error {
+	if transactor, ok := dao.(Transactor); ok {
+		return transactor.InTransaction(ctx, fn)
+	}
+	return fn(ctx)
+}
+
+type transactionKey struct {
+	db *sql.DB
+}
+
+func transactionFrom(ctx context.Context, db *sql.DB) (*sql.Tx, bool) {
+	tx, ok := ctx.Value(transactionKey{db: db}).(*sql.Tx)
+	return tx, ok
+}
+
+// runInTransaction joins the ongoing transaction if any. Otherwise, it starts one and runs it again when retry recognizes
+// its failure, as serialization failures are expected from concurrent transactions.
+func runInTransaction(ctx context.Context, db *sql.DB, options *sql.TxOptions, retry func(error) bool, fn func(ctx context.Context) error) error {
+	if _, ongoing := transactionFrom(ctx, db); ongoing {
+		return fn(ctx)
+	}
+	for attempt := 1; ; attempt++ {
+		err := runTransaction(ctx, db, options, fn)
+		if nil == err || nil == retry || !retry(err) || maxTransactionAttempts <= attempt {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(attempt) * transactionRetryDelay):
+		}
+	}
+}
+
+func runTransaction(ctx context.Context, db *sql.DB, options *sql.TxOptions, fn func(ctx context.Context) error) (err error) {
+	tx, err := db.BeginTx(ctx, options)
+	if err != nil {
+		return errors.Wrapf(err, "Starting transaction")
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			if rollbackErr := tx.Rollback(); nil != rollbackErr && nil == err {
+				err = errors.Wrapf(rollbackErr, "Rolling back transaction")
+			}
+		}
+	}()
+	if err = fn(context.WithValue(ctx, transactionKey{db: db}, tx)); nil != err {
+		return err
+	}
+	committed = true
+	if err = tx.Commit(); nil != err {
+		return errors.Wrapf(err, "Committing transaction")
+	}
+	return nil
+}
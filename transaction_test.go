@@ -0,0 +1,79 @@
+package rest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/normegil/rest"
+)
+
+func TestInTransactionRollback(t *testing.T) {
+	mapper, err := rest.NewStructMapper(sqlEntity{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dao := newSQLiteDAO(t, mapper, transactionalQueries{rest.NewMapperQueries(rest.SQLite, "things", mapper)})
+	if _, err = dao.Set(sqlEntity{Identifier: "existing", Name: "existing", Size: 1}); nil != err {
+		t.Fatal(err)
+	}
+
+	failure := errors.New("failure")
+	err = rest.InTransaction(context.Background(), dao, func(ctx context.Context) error {
+		if _, err := dao.SetContext(ctx, sqlEntity{Name: "created"}); nil != err {
+			return err
+		}
+		if _, err := dao.SetContext(ctx, sqlEntity{Identifier: "existing", Name: "updated", Size: 2}); nil != err {
+			return err
+		}
+		// Nested transactions join the ongoing one
+		if err := rest.InTransaction(ctx, dao, func(ctx context.Context) error {
+			return dao.DeleteContext(ctx, rest.StringIdentifier("existing"))
+		}); nil != err {
+			return err
+		}
+		if nb, err := dao.TotalNumberOfEntitiesContext(ctx); nil != err || 1 != nb {
+			t.Errorf("Operations aren't visible inside the transaction: %d entities (%+v)", nb, err)
+		}
+		return failure
+	})
+	if failure != err {
+		t.Errorf("Expected the callback error but got %+v", err)
+	}
+
+	entities, err := dao.GetAllEntities(rest.Pagination{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := sqlEntity{Identifier: "existing", Name: "existing", Size: 1}
+	if 1 != len(entities) || expected != entities[0] {
+		t.Errorf("Entities (%+v) weren't rolled back to %+v", entities, expected)
+	}
+}
+
+func TestInTransactionWithoutTransactor(t *testing.T) {
+	dao := struct{ rest.DAO }{rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{})}
+	failure := errors.New("failure")
+	called := false
+	err := rest.InTransaction(context.Background(), dao, func(ctx context.Context) error {
+		called = true
+		return failure
+	})
+	if !called || failure != err {
+		t.Errorf("Callback wasn't run directly {called:%t;err:%+v}", called, err)
+	}
+}
+
+func TestMemoryDAONestedTransaction(t *testing.T) {
+	dao := rest.NewMemoryDAO(rest.UUIDIdentifierGenerator{})
+	nested := false
+	err := rest.InTransaction(context.Background(), dao, func(ctx context.Context) error {
+		return rest.InTransaction(ctx, dao, func(ctx context.Context) error {
+			nested = true
+			return nil
+		})
+	})
+	if nil != err || !nested {
+		t.Errorf("Nested transaction wasn't run {nested:%t;err:%+v}", nested, err)
+	}
+}
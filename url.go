@@ -2,15 +2,141 @@ package rest
 
 import (
 	"context"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
 )
 
 const FULL_URL_KEY = "RequestURL"
+const BASE_URL_KEY = "BaseURL"
 
 func URLContructor(h http.Handler) http.Handler {
+	return URLContructorWithResolver(&BaseURLResolver{}, h)
+}
+
+// URLContructorWithResolver stores the request URL and the base URL computed by resolver in the request context.
+func URLContructorWithResolver(resolver *BaseURLResolver, h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		url := r.URL.String()
 		ctx := context.WithValue(r.Context(), FULL_URL_KEY, url)
+		ctx = context.WithValue(ctx, BASE_URL_KEY, resolver.Resolve(r))
 		h.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// BaseURLResolver computes the scheme, host and path prefix under which clients reach the server.
+// Forwarded, X-Forwarded-Proto, X-Forwarded-Host and X-Forwarded-Prefix are only used when the request comes from a trusted proxy.
+type BaseURLResolver struct {
+	trustedProxies []*net.IPNet
+}
+
+// NewBaseURLResolver accepts trusted proxies as IP addresses or CIDR ranges.
+func NewBaseURLResolver(trustedProxies ...string) (*BaseURLResolver, error) {
+	resolver := &BaseURLResolver{}
+	for _, proxy := range trustedProxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if nil == ip {
+				return nil, errors.Errorf("Invalid trusted proxy address '%s'", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if nil != ip.To4() {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			resolver.trustedProxies = append(resolver.trustedProxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Parsing trusted proxy range '%s'", proxy)
+		}
+		resolver.trustedProxies = append(resolver.trustedProxies, network)
+	}
+	return resolver, nil
+}
+
+func (b *BaseURLResolver) trusts(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if nil == ip {
+		return false
+	}
+	for _, network := range b.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the base URL of r, without the request path.
+func (b *BaseURLResolver) Resolve(r *http.Request) *url.URL {
+	base := &url.URL{Scheme: "http", Host: r.Host}
+	if nil != r.TLS {
+		base.Scheme = "https"
+	}
+	if !b.trusts(r.RemoteAddr) {
+		return base
+	}
+
+	proto, host := forwarded(r.Header.Get("Forwarded"))
+	if "" == proto {
+		proto = lastValue(r.Header.Get("X-Forwarded-Proto"))
+	}
+	if "" == host {
+		host = lastValue(r.Header.Get("X-Forwarded-Host"))
+	}
+	if proto = strings.ToLower(proto); "http" == proto || "https" == proto {
+		base.Scheme = proto
+	}
+	if "" != host && !strings.ContainsAny(host, "/?#@ ") {
+		base.Host = host
+	}
+	if prefix := strings.Trim(lastValue(r.Header.Get("X-Forwarded-Prefix")), "/"); "" != prefix {
+		base.Path = "/" + prefix
+	}
+	return base
+}
+
+// forwarded extracts proto and host from the last element of an RFC 7239 Forwarded header.
+func forwarded(header string) (string, string) {
+	var proto, host string
+	element := lastValue(header)
+	for _, pair := range strings.Split(element, ";") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if 2 != len(parts) {
+			continue
+		}
+		value := strings.Trim(parts[1], `"`)
+		switch strings.ToLower(parts[0]) {
+		case "proto":
+			proto = value
+		case "host":
+			host = value
+		}
+	}
+	return proto, host
+}
+
+// lastValue returns the value appended by the trusted proxy which sent the request. Proxies append their value to the
+// ones received, so the values before it may come from anybody.
+func lastValue(header string) string {
+	values := strings.Split(header, ",")
+	return strings.TrimSpace(values[len(values)-1])
+}
+
+// BaseURL returns the base URL stored by URLContructor, or resolves it without trusting any proxy.
+func BaseURL(r *http.Request) *url.URL {
+	if base, ok := r.Context().Value(BASE_URL_KEY).(*url.URL); ok {
+		copied := *base
+		return &copied
+	}
+	return (&BaseURLResolver{}).Resolve(r)
+}
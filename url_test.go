@@ -43,3 +43,41 @@ func TestURLConstructor(t *testing.T) {
 		})
 	}
 }
+
+func TestBaseURLResolver(t *testing.T) {
+	resolver, err := rest.NewBaseURLResolver("10.0.0.1", "192.168.0.0/16")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testcases := []struct {
+		name       string
+		url        string
+		remoteAddr string
+		headers    map[string]string
+		expected   string
+	}{
+		{"Plain HTTP", "http://localhost/resource", "1.2.3.4:1234", nil, "http://localhost"},
+		{"TLS", "https://localhost/resource", "1.2.3.4:1234", nil, "https://localhost"},
+		{"Untrusted forwarded headers", "http://localhost/resource", "1.2.3.4:1234", map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "evil.com"}, "http://localhost"},
+		{"Trusted X-Forwarded headers", "http://localhost/resource", "10.0.0.1:1234", map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "spoofed.com, api.example.com", "X-Forwarded-Prefix": "/v1/"}, "https://api.example.com/v1"},
+		{"Trusted range", "http://localhost/resource", "192.168.3.4:1234", map[string]string{"X-Forwarded-Proto": "https"}, "https://localhost"},
+		{"Forwarded header", "http://localhost/resource", "10.0.0.1:1234", map[string]string{"Forwarded": `for=192.0.2.60;proto=http;host="spoofed.com", for=192.0.2.60;proto=https;host="example.com"`, "X-Forwarded-Host": "ignored.com"}, "https://example.com"},
+		{"Spoofed forwarded values", "http://localhost/resource", "10.0.0.1:1234", map[string]string{"X-Forwarded-Proto": "http, https", "X-Forwarded-Prefix": "/spoofed, /v2"}, "https://localhost/v2"},
+		{"Invalid proto", "http://localhost/resource", "10.0.0.1:1234", map[string]string{"X-Forwarded-Proto": "javascript"}, "http://localhost"},
+	}
+	for _, testdata := range testcases {
+		t.Run(testdata.name, func(t *testing.T) {
+			request := httptest.NewRequest("GET", testdata.url, nil)
+			request.RemoteAddr = testdata.remoteAddr
+			for key, value := range testdata.headers {
+				request.Header.Set(key, value)
+			}
+			handler := rest.URLContructorWithResolver(resolver, http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				if base := rest.BaseURL(r).String(); testdata.expected != base {
+					t.Errorf("Base URL (%s) doesn't meet the expected result (%s)", base, testdata.expected)
+				}
+			}))
+			handler.ServeHTTP(httptest.NewRecorder(), request)
+		})
+	}
+}